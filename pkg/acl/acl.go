@@ -0,0 +1,105 @@
+package acl
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/kjbreil/syncer/pkg/control"
+)
+
+// Rule accepts or refuses remote writes to the fields matching Path.
+type Rule struct {
+	// Path is a control.Pattern, e.g. Config.Limits.* or Map[*].Status.
+	Path string
+	// Peers are the identities the rule applies to, an empty list applies to every peer.
+	Peers []string
+	// Allow accepts the writes when true and refuses them when false.
+	Allow bool
+}
+
+type rule struct {
+	Rule
+	pattern *control.Pattern
+}
+
+// ACL decides per path and per peer if writes from remote peers are accepted.
+// The most specific rule matching an entry decides, a longer path is more specific and with equal
+// length the path with fewer wildcards. Of two rules with equally specific paths a rule naming
+// Peers wins over a rule for every peer, then the refusing one wins. An entry replacing a path
+// protected by a refusing rule, like removing the parent map, is refused as well unless a rule
+// for the same path taking precedence allows it. Entries without a matching rule are accepted.
+type ACL struct {
+	rules []rule
+}
+
+// New creates an ACL from the rules.
+func New(rules ...Rule) (*ACL, error) {
+	a := &ACL{
+		rules: make([]rule, 0, len(rules)),
+	}
+	for _, r := range rules {
+		p, err := control.NewPattern(r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("acl rule %q: %w", r.Path, err)
+		}
+		a.rules = append(a.rules, rule{Rule: r, pattern: p})
+	}
+	return a, nil
+}
+
+// Allowed reports if the entry written by peer may be applied.
+func (a *ACL) Allowed(peer string, e *control.Entry) bool {
+	if a == nil {
+		return true
+	}
+
+	var decided *rule
+	for i := range a.rules {
+		r := &a.rules[i]
+		if !r.appliesTo(peer) {
+			continue
+		}
+		if !r.Allow && r.pattern.Covered(e) && !a.overridden(peer, r) {
+			return false
+		}
+		if !r.pattern.Match(e) {
+			continue
+		}
+		if decided == nil || r.moreSpecific(decided) {
+			decided = r
+		}
+	}
+
+	return decided == nil || decided.Allow
+}
+
+// overridden reports if a rule for the same path as the refusing rule allows peer to write it and
+// takes precedence.
+func (a *ACL) overridden(peer string, refusing *rule) bool {
+	for i := range a.rules {
+		r := &a.rules[i]
+		if r.Allow && r.appliesTo(peer) && r.pattern.String() == refusing.pattern.String() && r.moreSpecific(refusing) {
+			return true
+		}
+	}
+	return false
+}
+
+// moreSpecific reports if r takes precedence over other, longer paths win over shorter paths,
+// then paths with fewer wildcards, then rules naming peers and finally refusing rules.
+func (r *rule) moreSpecific(other *rule) bool {
+	if r.pattern.Len() != other.pattern.Len() {
+		return r.pattern.Len() > other.pattern.Len()
+	}
+	if r.pattern.Wildcards() != other.pattern.Wildcards() {
+		return r.pattern.Wildcards() < other.pattern.Wildcards()
+	}
+	if (len(r.Peers) > 0) != (len(other.Peers) > 0) {
+		return len(r.Peers) > 0
+	}
+	return !r.Allow && other.Allow
+}
+
+func (r *rule) appliesTo(peer string) bool {
+	return len(r.Peers) == 0 || slices.Contains(r.Peers, peer)
+}
@@ -0,0 +1,68 @@
+package acl
+
+import (
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/control"
+)
+
+func entry(keys ...string) *control.Entry {
+	e := &control.Entry{Key: []*control.Key{{Key: "data"}}}
+	for _, k := range keys {
+		e.Key = append(e.Key, &control.Key{Key: k})
+	}
+	return e
+}
+
+func TestACL_Allowed(t *testing.T) {
+	a, err := New(
+		Rule{Path: "Config.Limits.*", Allow: false},
+		Rule{Path: "Config.Limits.Public", Allow: true},
+		Rule{Path: "Config.Limits.*", Peers: []string{"admin"}, Allow: true},
+		Rule{Path: "Status", Peers: []string{"reporter"}, Allow: false},
+		Rule{Path: "Secret", Allow: false},
+		Rule{Path: "Secret", Peers: []string{"admin"}, Allow: false},
+		Rule{Path: "Secret", Peers: []string{"admin", "reporter"}, Allow: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		peer  string
+		entry *control.Entry
+		want  bool
+	}{
+		{name: "unprotected field", peer: "client", entry: entry("Name"), want: true},
+		{name: "protected field", peer: "client", entry: entry("Config", "Limits", "Max"), want: false},
+		{name: "more specific allow", peer: "client", entry: entry("Config", "Limits", "Public"), want: true},
+		{name: "parent of protected field", peer: "client", entry: entry("Config"), want: false},
+		{name: "peer specific allow wins", peer: "admin", entry: entry("Config", "Limits", "Max"), want: true},
+		{name: "parent of field allowed to peer", peer: "admin", entry: entry("Config"), want: true},
+		{name: "peer specific rule", peer: "reporter", entry: entry("Status"), want: false},
+		{name: "peer specific rule other peer", peer: "client", entry: entry("Status"), want: true},
+		{name: "equal peer specific rules deny wins", peer: "admin", entry: entry("Secret"), want: false},
+		{name: "peer specific allow over general deny", peer: "reporter", entry: entry("Secret"), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.Allowed(tt.peer, tt.entry); got != tt.want {
+				t.Errorf("Allowed(%q, %s) = %v, want %v", tt.peer, tt.entry.Path(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACL_Nil(t *testing.T) {
+	var a *ACL
+	if !a.Allowed("anyone", entry("Config")) {
+		t.Fatal("nil ACL should allow every write")
+	}
+}
+
+func TestNew_InvalidPath(t *testing.T) {
+	if _, err := New(Rule{Path: "Config..Limits"}); err == nil {
+		t.Fatal("expected error for invalid path")
+	}
+}
@@ -0,0 +1,128 @@
+package combined
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/deepcopy"
+)
+
+// Change describes an entry from a peer applied to the data.
+type Change struct {
+	// Path is the path of the entry, e.g. Map[a].Status.
+	Path string
+	// Old is a copy of the value at Path before the change, nil when it did not exist.
+	Old any
+	// New is a copy of the value at Path after the change, nil when it was removed.
+	New any
+	// Origin is the identity of the peer the entry came from, empty when not known.
+	Origin string
+	// Removed is true when the value at Path was removed.
+	Removed bool
+}
+
+type subscription struct {
+	pattern *control.Pattern
+	// fn is called for every change, set with all changes of a change set
+	fn  func(Change)
+	set func([]Change)
+}
+
+// Subscriptions holds the functions called for changes applied by Add and AddFrom. A single
+// Subscriptions can be shared by several Combined.
+type Subscriptions struct {
+	mu   sync.RWMutex
+	subs []*subscription
+}
+
+// NewSubscriptions creates an empty set of subscriptions.
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{}
+}
+
+// Add calls fn for every change at, below or above the path described by the pattern, e.g. a
+// subscription to Map[*].Status is called when Map[a].Status changes or Map[a] is removed.
+func (s *Subscriptions) Add(pattern *control.Pattern, fn func(Change)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs = append(s.subs, &subscription{pattern: pattern, fn: fn})
+}
+
+// AddSet calls fn once for every change set with the changes matching the pattern, see Add.
+func (s *Subscriptions) AddSet(pattern *control.Pattern, fn func([]Change)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs = append(s.subs, &subscription{pattern: pattern, set: fn})
+}
+
+// matching returns the subscriptions to changes of the entry.
+func (s *Subscriptions) matching(e *control.Entry) []*subscription {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var subs []*subscription
+	for _, sub := range s.subs {
+		if sub.pattern.Overlaps(e) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// valueAt returns a copy of the value the entry points to in data, nil when it does not exist.
+func valueAt(data any, e *control.Entry) any {
+	v := reflect.ValueOf(data)
+	for i, k := range e.GetKey() {
+		if i > 0 {
+			v = indirect(v)
+			if v.Kind() != reflect.Struct {
+				return nil
+			}
+			v = v.FieldByName(k.GetKey())
+		}
+		for _, idx := range k.GetIndex() {
+			v = index(indirect(v), idx)
+		}
+		if !v.IsValid() {
+			return nil
+		}
+	}
+	v = indirect(v)
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return deepcopy.Any(v.Interface())
+}
+
+// index returns the element of the map, slice or array at the index.
+func index(v reflect.Value, idx *control.Object) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if control.NewObject(iter.Key().Interface()).Equals(idx) {
+				return iter.Value()
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		i := int(idx.GetInt64())
+		if i >= 0 && i < v.Len() {
+			return v.Index(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// indirect follows pointers and interfaces, an invalid value is returned for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
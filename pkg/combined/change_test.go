@@ -0,0 +1,162 @@
+package combined
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/control"
+)
+
+type device struct {
+	Status string
+	Count  int
+}
+
+type devices struct {
+	Name string
+	Map  map[string]device
+}
+
+func TestSubscriptions(t *testing.T) {
+	data := &devices{Map: map[string]device{"a": {Status: "off"}}}
+	c, err := New(context.Background(), data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var changes []Change
+	subs := NewSubscriptions()
+	pattern, err := control.NewPattern("Map[*].Status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs.Add(pattern, func(ch Change) {
+		changes = append(changes, ch)
+	})
+	c.SetSubscriptions(subs)
+
+	mapEntry := func(key string, keys ...string) *control.Entry {
+		e := control.NewRemoveEntry(2)
+		e.Key = []*control.Key{{Key: "devices"}, {Key: "Map", Index: []*control.Object{control.NewObject(key)}}}
+		for _, k := range keys {
+			e.Key = append(e.Key, &control.Key{Key: k})
+		}
+		return e
+	}
+
+	status := mapEntry("a", "Status")
+	status.Remove = false
+	status.Value = control.NewObject("on")
+	count := mapEntry("a", "Count")
+	count.Remove = false
+	count.Value = control.NewObject(1)
+	name := control.NewEntry(2, "hub")
+	name.Key = []*control.Key{{Key: "devices"}, {Key: "Name"}}
+
+	for _, e := range []*control.Entry{status, count, name, mapEntry("a")} {
+		if err := c.AddFrom("peer", e); err != nil {
+			t.Fatalf("AddFrom() error = %v", err)
+		}
+	}
+
+	want := []Change{
+		{Path: "Map[a].Status", Old: "off", New: "on", Origin: "peer"},
+		{Path: "Map[a]", Old: device{Status: "on", Count: 1}, Origin: "peer", Removed: true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes %+v, want %+v", len(changes), changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestSubscriptions_AddSet(t *testing.T) {
+	data := &devices{Map: map[string]device{"a": {Status: "off"}}}
+	c, err := New(context.Background(), data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var sets [][]Change
+	subs := NewSubscriptions()
+	pattern, err := control.NewPattern("Map[*]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs.AddSet(pattern, func(chs []Change) {
+		sets = append(sets, chs)
+	})
+	c.SetSubscriptions(subs)
+
+	entry := func(key, field string, v any) *control.Entry {
+		e := control.NewEntry(3, v)
+		e.Key = []*control.Key{{Key: "devices"}, {Key: "Map", Index: []*control.Object{control.NewObject(key)}}, {Key: field}}
+		return e
+	}
+	name := control.NewEntry(2, "hub")
+	name.Key = []*control.Key{{Key: "devices"}, {Key: "Name"}}
+
+	applied, err := c.AddSetFrom("peer", control.Entries{entry("a", "Status", "on"), entry("b", "Count", 2), name})
+	if err != nil {
+		t.Fatalf("AddSetFrom() error = %v", err)
+	}
+	if len(applied) != 3 {
+		t.Fatalf("got %d applied entries, want 3", len(applied))
+	}
+	if len(sets) != 1 || len(sets[0]) != 2 {
+		t.Fatalf("got change sets %+v, want one set with 2 changes", sets)
+	}
+	if data.Name != "hub" || data.Map["a"].Status != "on" || data.Map["b"].Count != 2 {
+		t.Fatalf("unexpected data %+v", data)
+	}
+}
+
+// journalFunc is a Journal calling the function.
+type journalFunc func(entries ...*control.Entry) error
+
+func (f journalFunc) Append(entries ...*control.Entry) error {
+	return f(entries...)
+}
+
+// TestSubscriptions_LocalWrite verifies the new value of a change is the value the entry wrote,
+// not a local write made once the entry was applied.
+func TestSubscriptions_LocalWrite(t *testing.T) {
+	data := &simpleStruct{Age: 1}
+	c, err := New(context.Background(), data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mu := &sync.RWMutex{}
+	c.SetMutex(mu)
+
+	var changes []Change
+	subs := NewSubscriptions()
+	pattern, err := control.NewPattern("Age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs.Add(pattern, func(ch Change) {
+		changes = append(changes, ch)
+	})
+	c.SetSubscriptions(subs)
+	// the data is written locally right after the entry was applied
+	c.SetJournal(journalFunc(func(...*control.Entry) error {
+		mu.Lock()
+		data.Age = -1
+		mu.Unlock()
+		return nil
+	}))
+
+	entry := control.NewEntry(2, 2)
+	entry.Key = []*control.Key{{Key: "simpleStruct"}, {Key: "Age"}}
+	if err = c.AddFrom("peer", entry); err != nil {
+		t.Fatalf("AddFrom() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].Old != 1 || changes[0].New != 2 {
+		t.Fatalf("changes = %+v, want Age from 1 to 2", changes)
+	}
+}
@@ -0,0 +1,115 @@
+package combined
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/kjbreil/syncer/pkg/control"
+)
+
+// Version identifies a write by the Lamport timestamp it was made at and the ID of the endpoint
+// that made it.
+type Version struct {
+	Clock  uint64
+	Origin string
+}
+
+// Less orders versions by their clock, writes made at the same clock are ordered by origin so
+// every endpoint orders them the same way.
+func (v Version) Less(other Version) bool {
+	if v.Clock != other.Clock {
+		return v.Clock < other.Clock
+	}
+	return v.Origin < other.Origin
+}
+
+// Clock is the Lamport clock of an endpoint. It stamps the entries extracted from the data and
+// remembers the version of the last write to every path. A single Clock can be shared by several
+// Combined working on the same data.
+type Clock struct {
+	mu       sync.Mutex
+	origin   string
+	now      uint64
+	versions map[string]Version
+}
+
+// NewClock creates a clock for the endpoint identified by origin.
+func NewClock(origin string) *Clock {
+	return &Clock{origin: origin, versions: make(map[string]Version)}
+}
+
+// Origin returns the ID of the endpoint the clock stamps entries with.
+func (c *Clock) Origin() string {
+	return c.origin
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Version returns the version of the last write to the path and false when it is not known.
+func (c *Clock) Version(path string) (Version, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.versions[path]
+	return v, ok
+}
+
+// stamp advances the clock and stamps the entries, they are all part of the same write.
+func (c *Clock) stamp(entries control.Entries) {
+	if len(entries) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now++
+	for _, e := range entries {
+		e.Clock = c.now
+		e.Origin = c.origin
+		c.set(e, Version{Clock: c.now, Origin: c.origin})
+	}
+}
+
+// observe moves the clock past the version received from a peer.
+func (c *Clock) observe(v Version) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = max(c.now, v.Clock)
+}
+
+// record sets the version of the last write to the path of the entry.
+func (c *Clock) record(e *control.Entry, v Version) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(e, v)
+}
+
+// set sets the version of the path of the entry, the lock must be held. A removed path and the
+// paths below it are no longer tracked, a later write to them is the first one.
+func (c *Clock) set(e *control.Entry, v Version) {
+	path := e.Path()
+	if !e.GetRemove() {
+		c.versions[path] = v
+		return
+	}
+	for p := range c.versions {
+		if below(p, path) {
+			delete(c.versions, p)
+		}
+	}
+}
+
+// below reports if p is path or a path below it, e.g. Map[a].Status is below Map[a] and Map.
+func below(p, path string) bool {
+	return p == path || strings.HasPrefix(p, path+".") || strings.HasPrefix(p, path+"[")
+}
+
+// next returns the version the next local write will be stamped with.
+func (c *Clock) next() Version {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Version{Clock: c.now + 1, Origin: c.origin}
+}
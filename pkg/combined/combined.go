@@ -4,10 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/acl"
 	"github.com/kjbreil/syncer/pkg/control"
 	"github.com/kjbreil/syncer/pkg/extractor"
 	"github.com/kjbreil/syncer/pkg/injector"
-	"time"
+	"github.com/kjbreil/syncer/pkg/merkle"
+	"github.com/kjbreil/syncer/pkg/tags"
+	"google.golang.org/protobuf/proto"
 )
 
 // Combined represents a combined configuration of an extractor and an injector.
@@ -17,6 +23,25 @@ type Combined struct {
 	// injector is the configuration of the injector.
 	injector *injector.Injector
 
+	data          any
+	mu            *sync.RWMutex
+	subscriptions *Subscriptions
+	journal       Journal
+	clock         *Clock
+	conflicts     func(Conflict)
+	role          tags.Role
+	// writers holds the values written by peers so they are not sent back
+	writers *writers
+	// logged holds the pending entries written to the journal by path, Pending returns them until
+	// they are acknowledged but they are written once
+	loggedMu sync.Mutex
+	logged   map[string]*control.Entry
+
+	// resolverMu guards the resolvers, they can be set while entries are added
+	resolverMu sync.RWMutex
+	resolver   ConflictResolver
+	resolvers  map[ConflictPolicy]ConflictResolver
+
 	extractorChanges func() error
 	extractorChgChan chan struct{}
 	injectorChanges  func() error
@@ -31,8 +56,33 @@ type Combined struct {
 var (
 	ErrExtractorChangeFn = errors.New("failed to run extractor changes function")
 	ErrInjectorChangeFn  = errors.New("failed to run injector changes function")
+	ErrJournal           = errors.New("failed to write journal")
 )
 
+// Journal records the entries added to and extracted from the data, e.g. a persist.Log.
+type Journal interface {
+	Append(entries ...*control.Entry) error
+}
+
+// Shared is the state shared by every Combined working on the same data.
+type Shared struct {
+	// Mutex guards the data, see SetMutex.
+	Mutex *sync.RWMutex
+	// Subscriptions are called for the entries added, see SetSubscriptions.
+	Subscriptions *Subscriptions
+	// Journal records the entries, see SetJournal.
+	Journal Journal
+	// Clock stamps the entries and tracks the version of every path, see SetClock.
+	Clock *Clock
+	// Resolver resolves conflicting writes to fields without a conflict tag, see
+	// SetConflictResolver.
+	Resolver ConflictResolver
+	// Resolvers are the resolvers named by conflict tags, see SetResolver.
+	Resolvers map[ConflictPolicy]ConflictResolver
+	// Conflicts is called for every conflicting write, see SetConflictHandler.
+	Conflicts func(Conflict)
+}
+
 // New creates a new Combined instance.
 func New(ctx context.Context, data any) (*Combined, error) {
 	if ctx == nil {
@@ -42,7 +92,7 @@ func New(ctx context.Context, data any) (*Combined, error) {
 		return nil, errors.New("data is nil")
 	}
 	var err error
-	c := Combined{}
+	c := Combined{data: data, clock: NewClock(""), writers: newWriters(), logged: make(map[string]*control.Entry)}
 	c.ctx, c.cancel = context.WithCancel(ctx)
 
 	c.extractor, err = extractor.New(data)
@@ -106,6 +156,15 @@ func (c *Combined) extractorChangeDebounce() {
 	}
 }
 
+// signal starts the debounce of ch, it does not block once a signal is pending or the debounce
+// stopped with the context.
+func (c *Combined) signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
 // ExtractorChanges sets the function to be executed when the extractor configuration changes.
 func (c *Combined) ExtractorChanges(fn func() error) {
 	c.extractorChanges = fn
@@ -116,10 +175,251 @@ func (c *Combined) InjectorChanges(fn func() error) {
 	c.injectorChanges = fn
 }
 
-// Add adds a new entry to the control file.
+// Add adds a new entry to the control file. The entry is also applied to the extractor so it is
+// not sent back to the peer it came from.
 func (c *Combined) Add(cfg *control.Entry) error {
-	c.injectorChgChan <- struct{}{}
-	return c.injector.Add(cfg)
+	c.signal(c.injectorChgChan)
+	_, err := c.addSet("", false, control.Entries{cfg})
+	return err
+}
+
+// AddFrom adds a new entry written by a remote peer, the entry is checked against the access list.
+func (c *Combined) AddFrom(peer string, cfg *control.Entry) error {
+	_, err := c.AddSetFrom(peer, control.Entries{cfg})
+	return err
+}
+
+// AddSetFrom adds the entries written by a remote peer as a single change, when one of them
+// fails none is applied. Entries refused by the access list are marked rejected and the returned
+// error matches injector.ErrRejected, the other entries are applied. Set subscribers are called
+// once with the changes of all entries. It returns copies of the entries applied, without the
+// rejected ones and the ones losing a conflict.
+func (c *Combined) AddSetFrom(peer string, entries control.Entries) (control.Entries, error) {
+	applied, err := c.addSet(peer, true, entries)
+	if err != nil && !errors.Is(err, injector.ErrRejected) {
+		return nil, err
+	}
+	c.signal(c.injectorChgChan)
+	return applied, err
+}
+
+func (c *Combined) addSet(origin string, fromPeer bool, entries control.Entries) (control.Entries, error) {
+	// entries losing a conflict against a local write are left out
+	var kept control.Entries
+	for _, e := range entries {
+		if conflict, ok := c.conflict(e); ok {
+			conflict.Applied = c.resolve(&conflict, e)
+			if c.conflicts != nil {
+				c.conflicts(conflict)
+			}
+			if !conflict.Applied {
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+	if len(kept) == 0 {
+		return nil, nil
+	}
+
+	// the injector advances the key cursor of the entries, the extractor needs them untouched
+	baselines := make(control.Entries, len(kept))
+	matched := make([][]*subscription, len(kept))
+	changes := make([]*Change, len(kept))
+	for i, e := range kept {
+		baselines[i] = proto.Clone(e).(*control.Entry)
+		matched[i] = c.subscriptions.matching(baselines[i])
+		if len(matched[i]) > 0 {
+			changes[i] = &Change{Path: e.Path(), Origin: origin, Removed: e.GetRemove()}
+		}
+	}
+
+	// the baseline is updated while the extractor is locked so Entries and Pending never see the
+	// entries applied to the data but not to the baseline, they would be sent back as local changes
+	var applied []int
+	var rejected error
+	err := c.extractor.Previous(func(previous any) error {
+		err := c.inject(origin, fromPeer, kept, baselines, changes)
+		if err != nil && !errors.Is(err, injector.ErrRejected) {
+			return err
+		}
+		rejected = err
+
+		for i, e := range kept {
+			if !e.GetRejected() {
+				applied = append(applied, i)
+			}
+		}
+		inj, err := injector.New(previous)
+		if err != nil {
+			return fmt.Errorf("failed to apply entry to extractor: %w", err)
+		}
+		for _, i := range applied {
+			err = inj.Add(baselines[i])
+			if err != nil {
+				return fmt.Errorf("failed to apply entry to extractor: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(control.Entries, len(applied))
+	for n, i := range applied {
+		done[n] = baselines[i].Rewind()
+		if b := baselines[i]; b.GetClock() > 0 {
+			c.clock.record(b, Version{Clock: b.GetClock(), Origin: b.GetOrigin()})
+		}
+	}
+	c.writers.wrote(origin, done)
+
+	if c.journal != nil && len(done) > 0 {
+		err = c.journal.Append(done...)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrJournal, err)
+		}
+	}
+
+	// set subscribers get all their changes at once, in the order they first matched
+	var sets []*subscription
+	setChanges := make(map[*subscription][]Change)
+	for _, i := range applied {
+		if len(matched[i]) == 0 {
+			continue
+		}
+		change := *changes[i]
+		for _, sub := range matched[i] {
+			if sub.set == nil {
+				sub.fn(change)
+				continue
+			}
+			if _, ok := setChanges[sub]; !ok {
+				sets = append(sets, sub)
+			}
+			setChanges[sub] = append(setChanges[sub], change)
+		}
+	}
+	for _, sub := range sets {
+		sub.set(setChanges[sub])
+	}
+	return done, rejected
+}
+
+// inject adds the entries to the data holding the write lock of the data, the old and new values of
+// the changes are copied under the same lock so no other write comes in between.
+func (c *Combined) inject(origin string, fromPeer bool, entries, baselines control.Entries, changes []*Change) error {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	for i, e := range entries {
+		if changes[i] != nil {
+			changes[i].Old = valueAt(c.data, e)
+		}
+	}
+	var err error
+	if fromPeer {
+		err = c.injector.AddSetFrom(origin, entries)
+	} else {
+		err = c.injector.AddSet(entries)
+	}
+	if err != nil && !errors.Is(err, injector.ErrRejected) {
+		return err
+	}
+	for i, e := range entries {
+		if changes[i] != nil && !changes[i].Removed && !e.GetRejected() {
+			changes[i].New = valueAt(c.data, baselines[i])
+		}
+	}
+	return err
+}
+
+// valueAt returns a copy of the value at the path of the entry holding the read lock of the data.
+func (c *Combined) valueAt(e *control.Entry) any {
+	if c.mu != nil {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+	return valueAt(c.data, e)
+}
+
+// SetRole sets the side of the connection, fields are only extracted and injected when the tags
+// of the field allow it for that side.
+func (c *Combined) SetRole(role tags.Role) {
+	c.role = role
+	c.extractor.SetRole(role)
+	c.injector.SetRole(role)
+}
+
+// SetMutex sets the mutex guarding the data, the extractor holds the read lock while copying the
+// data and the write lock is held while entries are applied.
+func (c *Combined) SetMutex(mu *sync.RWMutex) {
+	c.mu = mu
+	c.extractor.SetLocker(mu.RLocker())
+}
+
+// Share sets the mutex, subscriptions, journal, clock and conflict handling of the shared state.
+func (c *Combined) Share(s Shared) {
+	if s.Mutex != nil {
+		c.SetMutex(s.Mutex)
+	}
+	if s.Clock != nil {
+		c.SetClock(s.Clock)
+	}
+	c.SetSubscriptions(s.Subscriptions)
+	c.SetJournal(s.Journal)
+	c.SetConflictResolver(s.Resolver)
+	for name, r := range s.Resolvers {
+		c.SetResolver(name, r)
+	}
+	c.SetConflictHandler(s.Conflicts)
+}
+
+// SetClock sets the clock the extracted entries are stamped with.
+func (c *Combined) SetClock(clock *Clock) {
+	c.clock = clock
+}
+
+// SetConflictResolver sets the resolver deciding which write is kept when an entry added
+// conflicts with a local write to a field without a conflict tag, LastWriterWins when nil.
+func (c *Combined) SetConflictResolver(r ConflictResolver) {
+	c.resolverMu.Lock()
+	defer c.resolverMu.Unlock()
+	c.resolver = r
+}
+
+// SetResolver registers the resolver for fields tagged with its name, e.g.
+// `syncer:"conflict=merge"` for the name merge. Registering a built-in name replaces it.
+func (c *Combined) SetResolver(name ConflictPolicy, r ConflictResolver) {
+	c.resolverMu.Lock()
+	defer c.resolverMu.Unlock()
+	if c.resolvers == nil {
+		c.resolvers = make(map[ConflictPolicy]ConflictResolver)
+	}
+	c.resolvers[name] = r
+}
+
+// SetConflictHandler sets the function called for every conflicting entry added, it is called
+// from the goroutine adding the entry and must not block.
+func (c *Combined) SetConflictHandler(fn func(Conflict)) {
+	c.conflicts = fn
+}
+
+// SetJournal sets the journal the entries added and extracted are appended to.
+func (c *Combined) SetJournal(j Journal) {
+	c.journal = j
+}
+
+// SetSubscriptions sets the subscriptions called for the entries added.
+func (c *Combined) SetSubscriptions(subs *Subscriptions) {
+	c.subscriptions = subs
+}
+
+// SetACL sets the access list entries added from remote peers are checked against.
+func (c *Combined) SetACL(a *acl.ACL) {
+	c.injector.SetACL(a)
 }
 
 // Reset resets the Combined instance.
@@ -127,16 +427,169 @@ func (c *Combined) Reset() {
 	c.extractor.Reset()
 }
 
-// Diff returns the difference between the current configuration and the given data.
+// Entries returns the difference between the current configuration and the given data stamped
+// with the next time of the clock. Values written by peers are left out, see LastWriter.
+// When the entries cannot be written to the journal they are returned with an ErrJournal error.
 func (c *Combined) Entries(data any) (control.Entries, error) {
 	entries, err := c.extractor.Entries(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create diff in extractor: %w", err)
 	}
-	c.extractorChgChan <- struct{}{}
+	entries, _ = c.writers.split(entries)
+	c.signal(c.extractorChgChan)
+	c.clock.stamp(entries)
+	if c.journal != nil && len(entries) > 0 {
+		err = c.journal.Append(entries...)
+		if err != nil {
+			return entries, fmt.Errorf("%w: %w", ErrJournal, err)
+		}
+	}
+	return entries, nil
+}
+
+// Resume makes the state from compares the data against the state of c, c continues from the
+// data as last exchanged by from, e.g. once the context of from is done.
+func (c *Combined) Resume(from *Combined) error {
+	entries, err := from.snapshot(tags.Any)
+	if err != nil {
+		return err
+	}
+	return c.previous(entries)
+}
+
+// Snapshot returns the entries of the entire state the data is compared against, the data as it
+// was sent last. The entries carry the version of their last write when it is known.
+func (c *Combined) Snapshot() (control.Entries, error) {
+	return c.snapshot(c.role)
+}
+
+// Tree returns the hash tree of the state the data is compared against, see Snapshot. Only the
+// fields sent by a server are hashed so a server and its clients holding the same data build the
+// same tree.
+func (c *Combined) Tree() (*merkle.Tree, error) {
+	entries, err := c.snapshot(tags.Server)
+	if err != nil {
+		return nil, err
+	}
+	return merkle.New(entries), nil
+}
+
+// snapshot returns the entries of the state the data is compared against extracted for role.
+func (c *Combined) snapshot(role tags.Role) (control.Entries, error) {
+	var entries control.Entries
+	err := c.extractor.Previous(func(previous any) error {
+		ext, err := extractor.New(previous)
+		if err != nil {
+			return err
+		}
+		ext.SetRole(role)
+		entries, err = ext.Entries(previous)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot in extractor: %w", err)
+	}
+	for _, e := range entries {
+		if v, ok := c.clock.Version(e.Path()); ok {
+			e.Clock, e.Origin = v.Clock, v.Origin
+		}
+	}
 	return entries, nil
 }
 
+// Pending returns the difference between the data and the entries acknowledged so far, stamped
+// with the next time of the clock. The entries are returned again by the next call until they are
+// passed to Acknowledge. Values written by peers are left out, see LastWriter.
+// The entries are written to the journal once, when they cannot be written they are returned with
+// an ErrJournal error.
+func (c *Combined) Pending(data any) (control.Entries, error) {
+	entries, err := c.extractor.Diff(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create diff in extractor: %w", err)
+	}
+	entries, echoes := c.writers.split(entries)
+	// e.g. a write of a peer arriving while a local write to the path waited for its acknowledgement
+	err = c.previous(echoes)
+	if err != nil {
+		return nil, err
+	}
+	c.clock.stamp(entries)
+	return entries, c.log(entries)
+}
+
+// log writes the pending entries not written yet to the journal.
+func (c *Combined) log(entries control.Entries) error {
+	c.loggedMu.Lock()
+	defer c.loggedMu.Unlock()
+	var unlogged control.Entries
+	for _, e := range entries {
+		l, ok := c.logged[e.Path()]
+		if ok && l.GetRemove() == e.GetRemove() && proto.Equal(l.GetValue(), e.GetValue()) {
+			continue
+		}
+		c.logged[e.Path()] = e
+		unlogged = append(unlogged, e)
+	}
+	if c.journal == nil || len(unlogged) == 0 {
+		return nil
+	}
+	err := c.journal.Append(unlogged...)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrJournal, err)
+	}
+	return nil
+}
+
+// LastWriter returns the peer that wrote the value at path and false when the value was written
+// locally. The value is not sent while it is unchanged.
+func (c *Combined) LastWriter(path string) (string, bool) {
+	return c.writers.origin(path)
+}
+
+// Acknowledge applies the entries returned by Pending to the previous state of the extractor once
+// the peer applied them.
+func (c *Combined) Acknowledge(entries control.Entries) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	err := c.previous(entries)
+	if err != nil {
+		return err
+	}
+	c.signal(c.extractorChgChan)
+	c.loggedMu.Lock()
+	for _, e := range entries {
+		delete(c.logged, e.Path())
+	}
+	c.loggedMu.Unlock()
+	return nil
+}
+
+// previous applies the entries to the previous state of the extractor.
+func (c *Combined) previous(entries control.Entries) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	err := c.extractor.Previous(func(previous any) error {
+		inj, err := injector.New(previous)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			// the injector advances the key cursor, the entries can be sent again
+			err = inj.Add(proto.Clone(e).(*control.Entry))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply entries to extractor: %w", err)
+	}
+	return nil
+}
+
 // Close stops the Combined instance and closes all open resources.
 func (c *Combined) Close() error {
 	c.cancel()
@@ -0,0 +1,147 @@
+package combined
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/control"
+)
+
+// TestAddNotExtracted verifies entries added from a peer are not extracted again while changes made
+// locally in the meantime still are.
+func TestAddNotExtracted(t *testing.T) {
+	data := &simpleStruct{}
+	c, err := New(context.Background(), data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry := control.NewEntry(2, "Bob")
+	entry.Key = []*control.Key{{Key: "simpleStruct"}, {Key: "Name"}}
+
+	data.Age = 5
+	if err := c.Add(entry); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	entries, err := c.Entries(data)
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path() != "Age" {
+		t.Fatalf("expected only the local Age change, got %v", entries)
+	}
+}
+
+// TestPendingAcknowledge verifies pending entries are returned until they are acknowledged.
+func TestPendingAcknowledge(t *testing.T) {
+	data := &simpleStruct{Name: "Bob"}
+	c, err := New(context.Background(), data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for range 2 {
+		entries, err := c.Pending(data)
+		if err != nil {
+			t.Fatalf("Pending() error = %v", err)
+		}
+		if len(entries) != 1 || entries[0].Path() != "Name" {
+			t.Fatalf("expected the Name change, got %v", entries)
+		}
+		if entries[0].GetClock() == 0 {
+			t.Fatal("entry not stamped")
+		}
+		if err = c.Acknowledge(nil); err != nil {
+			t.Fatalf("Acknowledge() error = %v", err)
+		}
+	}
+
+	entries, _ := c.Pending(data)
+	if err = c.Acknowledge(entries); err != nil {
+		t.Fatalf("Acknowledge() error = %v", err)
+	}
+	data.Age = 3
+	entries, _ = c.Pending(data)
+	if len(entries) != 1 || entries[0].Path() != "Age" {
+		t.Fatalf("expected only the Age change after acknowledging, got %v", entries)
+	}
+}
+
+// TestAddFromNotPending verifies entries added from a peer are never seen as local changes, not
+// even by a Pending running while they are applied.
+func TestAddFromNotPending(t *testing.T) {
+	data := &simpleStruct{}
+	c, err := New(context.Background(), data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.SetMutex(&sync.RWMutex{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 500; i++ {
+			entry := control.NewEntry(2, i)
+			entry.Key = []*control.Key{{Key: "simpleStruct"}, {Key: "Age"}}
+			if err := c.AddFrom("peer", entry); err != nil {
+				t.Errorf("AddFrom() error = %v", err)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		entries, err := c.Pending(data)
+		if err != nil {
+			t.Fatalf("Pending() error = %v", err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("the write of the peer is pending as a local change: %v", entries)
+		}
+	}
+}
+
+// TestPendingJournal verifies pending entries are written to the journal once when they are
+// extracted, before they are acknowledged.
+func TestPendingJournal(t *testing.T) {
+	data := &simpleStruct{Name: "Bob"}
+	c, err := New(context.Background(), data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var journaled []string
+	c.SetJournal(journalFunc(func(entries ...*control.Entry) error {
+		for _, e := range entries {
+			journaled = append(journaled, e.Path())
+		}
+		return nil
+	}))
+
+	for range 2 {
+		if _, err = c.Pending(data); err != nil {
+			t.Fatalf("Pending() error = %v", err)
+		}
+	}
+	if len(journaled) != 1 || journaled[0] != "Name" {
+		t.Fatalf("journaled %v, want Name once", journaled)
+	}
+
+	data.Name = "Alice"
+	entries, _ := c.Pending(data)
+	if len(journaled) != 2 {
+		t.Fatalf("journaled %v, want the new Name", journaled)
+	}
+	if err = c.Acknowledge(entries); err != nil {
+		t.Fatalf("Acknowledge() error = %v", err)
+	}
+	if len(journaled) != 2 {
+		t.Fatalf("journaled %v, want nothing written on acknowledge", journaled)
+	}
+}
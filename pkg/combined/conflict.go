@@ -0,0 +1,276 @@
+package combined
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/crdt"
+	"github.com/kjbreil/syncer/pkg/equal"
+	"github.com/kjbreil/syncer/pkg/tags"
+)
+
+// ConflictResolver decides which write is kept when an entry from a peer conflicts with a local
+// write to the same path.
+type ConflictResolver interface {
+	// Resolve returns true to apply the entry from the peer and false to keep the local write.
+	Resolve(c Conflict) bool
+}
+
+// ResolveFunc is a ConflictResolver calling the function with both values of the conflict.
+type ResolveFunc func(c Conflict) bool
+
+// Resolve calls f.
+func (f ResolveFunc) Resolve(c Conflict) bool {
+	return f(c)
+}
+
+// ConflictPolicy names a ConflictResolver, the built-in policies resolve conflicts themselves and
+// other names are registered with SetResolver. Policies are picked per field with a tag like
+// `syncer:"conflict=server-wins"`.
+type ConflictPolicy string
+
+const (
+	// LastWriterWins keeps the write with the higher Version, every endpoint picks the same write.
+	LastWriterWins ConflictPolicy = "last-writer-wins"
+	// ServerWins keeps the write of the server.
+	ServerWins ConflictPolicy = "server-wins"
+	// ClientWins keeps the write of the client.
+	ClientWins ConflictPolicy = "client-wins"
+	// HighestIDWins keeps the write of the endpoint with the highest ID.
+	HighestIDWins ConflictPolicy = "highest-id-wins"
+	// KeepLocal keeps the local write, endpoints with this policy can keep different values.
+	KeepLocal ConflictPolicy = "keep-local"
+	// AcceptRemote applies the entry from the peer.
+	AcceptRemote ConflictPolicy = "accept-remote"
+)
+
+// Conflict describes an entry from a peer written without knowing about a local write to the
+// same path.
+type Conflict struct {
+	// Path is the path of the entry, e.g. Map[a].Status.
+	Path string
+	// Local is a copy of the local value at Path, nil when it does not exist.
+	Local any
+	// Remote is the value of the entry, nil when the entry removes the value.
+	Remote any
+	// LocalVersion is the version of the local write, a local write that is not sent yet has the
+	// version it will be sent with.
+	LocalVersion Version
+	// RemoteVersion is the version of the entry.
+	RemoteVersion Version
+	// Role is the side of the connection of the local endpoint, the peer is on the other side.
+	Role tags.Role
+	// Policy is the name of the resolver that decided the conflict.
+	Policy ConflictPolicy
+	// Applied is true when the entry from the peer was applied.
+	Applied bool
+}
+
+// String returns a description of the conflict for logging.
+func (c Conflict) String() string {
+	kept := "local"
+	if c.Applied {
+		kept = "remote"
+	}
+	return fmt.Sprintf("conflict at %s: local %v (%d@%s), remote %v (%d@%s), kept %s",
+		c.Path, c.Local, c.LocalVersion.Clock, c.LocalVersion.Origin,
+		c.Remote, c.RemoteVersion.Clock, c.RemoteVersion.Origin, kept)
+}
+
+// Resolve returns true when the built-in policy keeps the write from the peer, unknown policies
+// resolve like LastWriterWins.
+func (p ConflictPolicy) Resolve(c Conflict) bool {
+	switch p {
+	case ServerWins:
+		return c.Role == tags.Client
+	case ClientWins:
+		return c.Role == tags.Server
+	case HighestIDWins:
+		return c.LocalVersion.Origin < c.RemoteVersion.Origin
+	case KeepLocal:
+		return false
+	case AcceptRemote:
+		return true
+	default:
+		return c.LocalVersion.Less(c.RemoteVersion)
+	}
+}
+
+// ErrConflictPolicy is returned by CheckPolicies for a policy that names no resolver.
+var ErrConflictPolicy = errors.New("unknown conflict policy")
+
+// CheckPolicies returns an ErrConflictPolicy error when the conflict tag of a field of data names
+// neither a built-in policy nor one of resolvers, conflicts at the field would be resolved by the
+// default resolver instead.
+func CheckPolicies(data any, resolvers map[ConflictPolicy]ConflictResolver) error {
+	var errs []error
+	seen := make(map[reflect.Type]bool)
+	var walk func(typ reflect.Type)
+	walk = func(typ reflect.Type) {
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Map || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || seen[typ] {
+			return
+		}
+		seen[typ] = true
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			if name := ConflictPolicy(tags.Parse(field).Conflict); name != "" && !name.Known(resolvers) {
+				errs = append(errs, fmt.Errorf("%w: %s of %s.%s", ErrConflictPolicy, name, typ.Name(), field.Name))
+			}
+			walk(field.Type)
+		}
+	}
+	walk(reflect.TypeOf(data))
+	return errors.Join(errs...)
+}
+
+// Known reports if the policy is built in or one of resolvers.
+func (p ConflictPolicy) Known(resolvers map[ConflictPolicy]ConflictResolver) bool {
+	_, ok := resolvers[p]
+	return ok || p.builtin()
+}
+
+// builtin reports if the policy is resolved by ConflictPolicy.Resolve.
+func (p ConflictPolicy) builtin() bool {
+	switch p {
+	case LastWriterWins, ServerWins, ClientWins, HighestIDWins, KeepLocal, AcceptRemote:
+		return true
+	}
+	return false
+}
+
+// resolve decides the conflict with the resolver named by the tag of the field at its path, or
+// the default resolver when no field up the path has one or the name is not known.
+func (c *Combined) resolve(conflict *Conflict, e *control.Entry) bool {
+	conflict.Role = c.role
+	_, policy := fieldAt(reflect.TypeOf(c.data), e)
+	c.resolverMu.RLock()
+	r, ok := c.resolvers[policy]
+	def := c.resolver
+	c.resolverMu.RUnlock()
+	switch {
+	case ok:
+	case policy.builtin():
+		r = policy
+	default:
+		r = def
+		if r == nil {
+			r = LastWriterWins
+		}
+		policy, _ = r.(ConflictPolicy)
+	}
+	conflict.Policy = policy
+	return r.Resolve(*conflict)
+}
+
+// fieldAt returns the type at the path of the entry, walking the fields of typ, and the conflict
+// option of the deepest field on the path having one. The type is nil when the path is not found.
+func fieldAt(typ reflect.Type, e *control.Entry) (reflect.Type, ConflictPolicy) {
+	var policy ConflictPolicy
+	for i, k := range e.GetKey() {
+		if i > 0 {
+			typ = elem(typ)
+			if typ.Kind() != reflect.Struct {
+				return nil, policy
+			}
+			field, ok := typ.FieldByName(k.GetKey())
+			if !ok {
+				return nil, policy
+			}
+			if name := tags.Parse(field).Conflict; name != "" {
+				policy = ConflictPolicy(name)
+			}
+			typ = field.Type
+		}
+		for range k.GetIndex() {
+			typ = elem(typ)
+			if typ.Kind() == reflect.Map || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+				typ = typ.Elem()
+			}
+		}
+	}
+	return elem(typ), policy
+}
+
+// elem follows pointer types.
+func elem(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// conflict checks the entry against the last write to its path. Entries without a clock come from
+// peers not tracking versions and never conflict, neither do mergeable values. Nothing is reported
+// for paths never written before so the initial state sent by a peer is always applied.
+func (c *Combined) conflict(e *control.Entry) (Conflict, bool) {
+	remote := Version{Clock: e.GetClock(), Origin: e.GetOrigin()}
+	if remote.Clock == 0 {
+		return Conflict{}, false
+	}
+	if typ, _ := fieldAt(reflect.TypeOf(c.data), e); typ != nil && crdt.Is(typ) {
+		c.clock.observe(remote)
+		return Conflict{}, false
+	}
+	path := e.Path()
+	last, known := c.clock.Version(path)
+	c.clock.observe(remote)
+	if !known {
+		return Conflict{}, false
+	}
+
+	conflict := Conflict{Path: path, RemoteVersion: remote}
+	local := c.valueAt(e)
+	switch {
+	case c.pending(e, local):
+		// the local write is sent after the entry so the peer has not seen it
+		conflict.LocalVersion = c.clock.next()
+	case last.Origin != remote.Origin && remote.Clock <= last.Clock:
+		// a write made after seeing the last write would have a higher clock
+		conflict.LocalVersion = last
+	default:
+		return Conflict{}, false
+	}
+
+	conflict.Local = local
+	if !e.GetRemove() {
+		conflict.Remote = remoteValue(local, e.GetValue())
+	}
+	return conflict, true
+}
+
+// pending returns true when the local value at the path of the entry was changed since the data
+// was last extracted.
+func (c *Combined) pending(e *control.Entry, local any) bool {
+	var previous any
+	extracted := false
+	_ = c.extractor.Previous(func(data any) error {
+		previous = valueAt(data, e)
+		extracted = true
+		return nil
+	})
+	if !extracted {
+		return false
+	}
+	return !equal.Equal(reflect.ValueOf(local), reflect.ValueOf(previous))
+}
+
+// remoteValue decodes the value of an entry into the type of the local value, the object itself
+// is returned when the type is not known.
+func remoteValue(local any, o *control.Object) any {
+	if o == nil {
+		return nil
+	}
+	if local == nil {
+		return o
+	}
+	v := reflect.New(reflect.TypeOf(local)).Elem()
+	if o.SetValue(v) != nil {
+		return o
+	}
+	return v.Interface()
+}
@@ -0,0 +1,197 @@
+package combined
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
+)
+
+// syncedPair returns the Combined for the server data a and the client data b sharing shared,
+// with the initial state of a sent to b.
+func syncedPair(t *testing.T, a, b any, shared Shared) (*Combined, *Combined) {
+	t.Helper()
+	ca, err := New(context.Background(), a)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ca.SetClock(NewClock("a"))
+	ca.SetRole(tags.Server)
+	cb, err := New(context.Background(), b)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	shared.Clock = NewClock("b")
+	cb.Share(shared)
+	cb.SetRole(tags.Client)
+
+	if _, err = cb.Entries(b); err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	send(t, ca, a, cb)
+	return ca, cb
+}
+
+// send adds the entries extracted from data by from to to.
+func send(t *testing.T, from *Combined, data any, to *Combined) {
+	t.Helper()
+	entries, err := from.Entries(data)
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	for _, e := range entries {
+		if err = to.AddFrom(from.clock.Origin(), e); err != nil {
+			t.Fatalf("AddFrom() error = %v", err)
+		}
+	}
+}
+
+// TestConflict_Resolvers verifies concurrent writes to the same field are reported and both sides
+// end with the value chosen by the resolver of the client.
+func TestConflict_Resolvers(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver ConflictResolver
+		want     string
+	}{
+		{name: "default", resolver: nil, want: "b"},
+		{name: "last writer", resolver: LastWriterWins, want: "b"},
+		{name: "server", resolver: ServerWins, want: "a"},
+		{name: "client", resolver: ClientWins, want: "b"},
+		{name: "highest id", resolver: HighestIDWins, want: "b"},
+		{name: "keep local", resolver: KeepLocal, want: "b"},
+		{name: "accept remote", resolver: AcceptRemote, want: "a"},
+		{name: "func", resolver: ResolveFunc(func(c Conflict) bool { return c.Remote == "a" }), want: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := &simpleStruct{Name: "start"}, &simpleStruct{}
+			var conflicts []Conflict
+			ca, cb := syncedPair(t, a, b, Shared{Resolver: tt.resolver, Conflicts: func(c Conflict) {
+				conflicts = append(conflicts, c)
+			}})
+			if b.Name != "start" {
+				t.Fatalf("initial state not applied: %+v", b)
+			}
+
+			a.Name, b.Name = "a", "b"
+			send(t, ca, a, cb)
+			send(t, cb, b, ca)
+
+			if a.Name != tt.want || b.Name != tt.want {
+				t.Fatalf("a = %q, b = %q, want %q", a.Name, b.Name, tt.want)
+			}
+			if len(conflicts) != 1 {
+				t.Fatalf("got %d conflicts, want 1: %v", len(conflicts), conflicts)
+			}
+			c := conflicts[0]
+			if c.Path != "Name" || c.Local != "b" || c.Remote != "a" || c.Role != tags.Client || c.Applied != (tt.want == "a") {
+				t.Fatalf("unexpected conflict %+v", c)
+			}
+			if c.RemoteVersion.Origin != "a" || !c.RemoteVersion.Less(c.LocalVersion) {
+				t.Fatalf("unexpected versions %+v", c)
+			}
+		})
+	}
+}
+
+// TestConflict_Sequential verifies writes made after seeing the last write and entries without a
+// clock are applied without a conflict.
+func TestConflict_Sequential(t *testing.T) {
+	a, b := &simpleStruct{Name: "start"}, &simpleStruct{}
+	conflicts := 0
+	ca, cb := syncedPair(t, a, b, Shared{Resolver: KeepLocal, Conflicts: func(Conflict) { conflicts++ }})
+
+	b.Name = "b"
+	send(t, cb, b, ca)
+	a.Name = "a"
+	send(t, ca, a, cb)
+	if b.Name != "a" {
+		t.Fatalf("sequential write not applied: %+v", b)
+	}
+
+	b.Age = 1
+	entry := control.NewEntry(2, 2)
+	entry.Key = []*control.Key{{Key: "simpleStruct"}, {Key: "Age"}}
+	if err := cb.AddFrom("old", entry); err != nil {
+		t.Fatalf("AddFrom() error = %v", err)
+	}
+	if b.Age != 2 || conflicts != 0 {
+		t.Fatalf("entry without clock not applied: %+v, %d conflicts", b, conflicts)
+	}
+}
+
+type conflictTagged struct {
+	Name  string
+	Owned string `syncer:"conflict=server-wins"`
+	Sub   struct {
+		Text string
+	} `syncer:"conflict=longest"`
+}
+
+// TestConflict_Tags verifies fields pick their resolver with a tag, including custom resolvers
+// registered by name.
+func TestConflict_Tags(t *testing.T) {
+	a, b := &conflictTagged{}, &conflictTagged{}
+	a.Name, a.Owned, a.Sub.Text = "start", "start", "start"
+	policies := make(map[string]ConflictPolicy)
+	longest := ResolveFunc(func(c Conflict) bool {
+		return len(c.Remote.(string)) > len(c.Local.(string))
+	})
+	ca, cb := syncedPair(t, a, b, Shared{
+		Resolver:  KeepLocal,
+		Resolvers: map[ConflictPolicy]ConflictResolver{"longest": longest},
+		Conflicts: func(c Conflict) { policies[c.Path] = c.Policy },
+	})
+
+	a.Name, a.Owned, a.Sub.Text = "server", "server", "server"
+	b.Name, b.Owned, b.Sub.Text = "client", "client", "client side"
+	send(t, ca, a, cb)
+
+	if b.Name != "client" || b.Owned != "server" || b.Sub.Text != "client side" {
+		t.Fatalf("unexpected client data %+v", b)
+	}
+	want := map[string]ConflictPolicy{"Name": KeepLocal, "Owned": ServerWins, "Sub.Text": "longest"}
+	if !maps.Equal(policies, want) {
+		t.Fatalf("policies = %v, want %v", policies, want)
+	}
+}
+
+// TestClock_Removed verifies the versions of a removed path and the paths below it are dropped.
+func TestClock_Removed(t *testing.T) {
+	key := func(k string) *control.Key {
+		return &control.Key{Key: "Map", Index: []*control.Object{control.NewObject(k)}}
+	}
+	status := func(k string) *control.Entry {
+		e := control.NewEntry(3, "on")
+		e.Key = []*control.Key{{Key: "devices"}, key(k), {Key: "Status"}}
+		return e
+	}
+	clock := NewClock("a")
+	clock.stamp(control.Entries{status("x"), status("y"), status("xy")})
+
+	removed := control.NewRemoveEntry(2)
+	removed.Key = []*control.Key{{Key: "devices"}, key("x")}
+	clock.record(removed, Version{Clock: 5, Origin: "b"})
+
+	for path, want := range map[string]bool{"Map[x]": false, "Map[x].Status": false, "Map[y].Status": true, "Map[xy].Status": true} {
+		if _, ok := clock.Version(path); ok != want {
+			t.Errorf("Version(%s) known = %t, want %t", path, ok, want)
+		}
+	}
+}
+
+// TestCheckPolicies verifies conflict tags naming no resolver are reported, also in nested types.
+func TestCheckPolicies(t *testing.T) {
+	if err := CheckPolicies(&conflictTagged{}, map[ConflictPolicy]ConflictResolver{"longest": KeepLocal}); err != nil {
+		t.Fatalf("CheckPolicies() error = %v", err)
+	}
+	err := CheckPolicies(&map[string][]*conflictTagged{}, nil)
+	if !errors.Is(err, ErrConflictPolicy) || !strings.Contains(err.Error(), "longest of conflictTagged.Sub") {
+		t.Fatalf("CheckPolicies() error = %v, want the unknown policy of Sub", err)
+	}
+}
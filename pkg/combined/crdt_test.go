@@ -0,0 +1,60 @@
+package combined
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/crdt"
+)
+
+type crdtStruct struct {
+	Hits    crdt.GCounter
+	Members crdt.ORSet[string]
+	Nodes   map[string]*crdt.PNCounter
+}
+
+// TestCRDT_ConcurrentChanges verifies concurrent changes to mergeable fields from both sides are
+// merged instead of overwritten and never reported as conflicts.
+func TestCRDT_ConcurrentChanges(t *testing.T) {
+	a := &crdtStruct{Nodes: map[string]*crdt.PNCounter{"n": {}}}
+	b := &crdtStruct{}
+	a.Hits.Inc("a", 1)
+	a.Members.Add("a", "alice")
+	a.Nodes["n"].Add("a", 1)
+	conflicts := 0
+	ca, cb := syncedPair(t, a, b, Shared{Conflicts: func(Conflict) { conflicts++ }})
+	if b.Hits.Value() != 1 || !b.Members.Contains("alice") || b.Nodes["n"] == nil {
+		t.Fatalf("initial state not applied: %+v", b)
+	}
+
+	a.Hits.Inc("a", 2)
+	b.Hits.Inc("b", 3)
+	a.Members.Add("a", "bob")
+	b.Members.Remove("alice")
+	a.Nodes["n"].Add("a", 5)
+	b.Nodes["n"].Add("b", -1)
+	send(t, ca, a, cb)
+	send(t, cb, b, ca)
+
+	for name, d := range map[string]*crdtStruct{"a": a, "b": b} {
+		members := d.Members.Values()
+		slices.Sort(members)
+		if d.Hits.Value() != 6 || !slices.Equal(members, []string{"bob"}) || d.Nodes["n"].Value() != 5 {
+			t.Fatalf("%s: hits = %d, members = %v, node = %d", name, d.Hits.Value(), members, d.Nodes["n"].Value())
+		}
+	}
+	if conflicts != 0 {
+		t.Fatalf("got %d conflicts, want 0", conflicts)
+	}
+
+	// nothing changed since the last exchange
+	for _, c := range []*Combined{ca, cb} {
+		entries, err := c.Entries(c.data)
+		if err != nil {
+			t.Fatalf("Entries() error = %v", err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("unexpected entries %v", entries)
+		}
+	}
+}
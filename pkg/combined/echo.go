@@ -0,0 +1,77 @@
+package combined
+
+import (
+	"sync"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"google.golang.org/protobuf/proto"
+)
+
+// writers remembers the last write added from a peer to every path. A change of the data to the
+// value a peer wrote is that write coming back and is not sent again.
+type writers struct {
+	mu     sync.Mutex
+	writes map[string]write
+}
+
+// write is a value written by a peer.
+type write struct {
+	origin string
+	entry  *control.Entry
+}
+
+func newWriters() *writers {
+	return &writers{writes: make(map[string]write)}
+}
+
+// wrote records the entries as written by origin. A removal is not recorded, the writes to the path
+// and below it are forgotten.
+func (w *writers) wrote(origin string, entries control.Entries) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, e := range entries {
+		if e.GetRemove() {
+			w.forget(e.Path())
+			continue
+		}
+		w.writes[e.Path()] = write{origin: origin, entry: e}
+	}
+}
+
+// forget drops the writes to path and below it, the lock must be held.
+func (w *writers) forget(path string) {
+	for p := range w.writes {
+		if below(p, path) {
+			delete(w.writes, p)
+		}
+	}
+}
+
+// origin returns the peer that wrote the value at path last and false when it was written locally.
+func (w *writers) origin(path string) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	wr, ok := w.writes[path]
+	return wr.origin, ok
+}
+
+// split separates the entries extracted from the data into local writes and the echoes of values
+// written by peers. The paths of the local writes are no longer written by a peer.
+func (w *writers) split(entries control.Entries) (local, echoes control.Entries) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, e := range entries {
+		path := e.Path()
+		if wr, ok := w.writes[path]; ok && wr.entry.GetRemove() == e.GetRemove() && proto.Equal(wr.entry.GetValue(), e.GetValue()) {
+			echoes = append(echoes, e)
+			continue
+		}
+		if e.GetRemove() {
+			w.forget(path)
+		} else {
+			delete(w.writes, path)
+		}
+		local = append(local, e)
+	}
+	return local, echoes
+}
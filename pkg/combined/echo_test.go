@@ -0,0 +1,97 @@
+package combined
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestEcho_NoPingPong verifies a value written by a peer is not sent back to it, even when a local
+// write to the same path was acknowledged after the value arrived.
+func TestEcho_NoPingPong(t *testing.T) {
+	a, b := &simpleStruct{Name: "start"}, &simpleStruct{}
+	ca, cb := syncedPair(t, a, b, Shared{Resolver: ServerWins})
+	ca.SetConflictResolver(ServerWins)
+
+	// the write of b waits for its acknowledgement while the write of a arrives
+	b.Name = "b"
+	inFlight, err := cb.Pending(b)
+	if err != nil {
+		t.Fatalf("Pending() error = %v", err)
+	}
+	a.Name = "a"
+	send(t, ca, a, cb)
+	for _, e := range inFlight {
+		if err = ca.AddFrom("b", e); err != nil {
+			t.Fatalf("AddFrom() error = %v", err)
+		}
+	}
+	if err = cb.Acknowledge(inFlight); err != nil {
+		t.Fatalf("Acknowledge() error = %v", err)
+	}
+	if writer, ok := cb.LastWriter("Name"); !ok || writer != "a" {
+		t.Fatalf("LastWriter() = %q, %t, want a", writer, ok)
+	}
+
+	for round := range 3 {
+		fromA, err := ca.Entries(a)
+		if err != nil {
+			t.Fatalf("Entries() error = %v", err)
+		}
+		fromB, err := cb.Pending(b)
+		if err != nil {
+			t.Fatalf("Pending() error = %v", err)
+		}
+		if len(fromA) != 0 || len(fromB) != 0 {
+			t.Fatalf("round %d: a sends %v, b sends %v", round, fromA, fromB)
+		}
+	}
+	if a.Name != "a" || b.Name != "a" {
+		t.Fatalf("a.Name = %q, b.Name = %q, want a", a.Name, b.Name)
+	}
+
+	// a local write to the path is sent again
+	b.Name = "again"
+	entries, _ := cb.Pending(b)
+	if len(entries) != 1 || entries[0].GetValue().GetString_() != "again" {
+		t.Fatalf("expected the local write, got %v", entries)
+	}
+	if _, ok := cb.LastWriter("Name"); ok {
+		t.Fatal("Name still written by a peer")
+	}
+}
+
+// TestEcho_RemovedForgotten verifies the writes of a peer are forgotten once their path is removed.
+func TestEcho_RemovedForgotten(t *testing.T) {
+	a, b := &devices{Map: map[string]device{}}, &devices{}
+	ca, cb := syncedPair(t, a, b, Shared{})
+
+	for _, key := range []string{"x", "y"} {
+		a.Map[key] = device{Status: "on", Count: 1}
+	}
+	send(t, ca, a, cb)
+	if _, ok := cb.LastWriter("Map[x].Status"); !ok {
+		t.Fatal("Map[x].Status not written by a peer")
+	}
+
+	delete(a.Map, "x")
+	send(t, ca, a, cb)
+	if _, ok := b.Map["x"]; ok {
+		t.Fatal("Map[x] not removed")
+	}
+	for path := range cb.writers.writes {
+		if strings.HasPrefix(path, "Map[x]") {
+			t.Fatalf("write to %s kept after Map[x] was removed", path)
+		}
+	}
+	if _, ok := cb.LastWriter("Map[y].Status"); !ok {
+		t.Fatal("Map[y].Status forgotten")
+	}
+
+	entries, err := cb.Pending(b)
+	if err != nil {
+		t.Fatalf("Pending() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("b sends %v", entries)
+	}
+}
@@ -26,6 +26,8 @@ type Message_ActionType int32
 const (
 	Message_PING     Message_ActionType = 0
 	Message_SHUTDOWN Message_ActionType = 1
+	// ELECT asks the server to step down for the Candidate.
+	Message_ELECT Message_ActionType = 2
 )
 
 // Enum value maps for Message_ActionType.
@@ -33,10 +35,12 @@ var (
 	Message_ActionType_name = map[int32]string{
 		0: "PING",
 		1: "SHUTDOWN",
+		2: "ELECT",
 	}
 	Message_ActionType_value = map[string]int32{
 		"PING":     0,
 		"SHUTDOWN": 1,
+		"ELECT":    2,
 	}
 )
 
@@ -159,12 +163,13 @@ func (x Request_RequestType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Request_RequestType.Descriptor instead.
 func (Request_RequestType) EnumDescriptor() ([]byte, []int) {
-	return file_control_proto_rawDescGZIP(), []int{2, 0}
+	return file_control_proto_rawDescGZIP(), []int{3, 0}
 }
 
 type Message struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Action        Message_ActionType     `protobuf:"varint,1,opt,name=action,proto3,enum=control.Message_ActionType" json:"action,omitempty"`
+	Candidate     *Leader                `protobuf:"bytes,2,opt,name=Candidate,proto3" json:"Candidate,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -206,9 +211,18 @@ func (x *Message) GetAction() Message_ActionType {
 	return Message_PING
 }
 
+func (x *Message) GetCandidate() *Leader {
+	if x != nil {
+		return x.Candidate
+	}
+	return nil
+}
+
 type Response struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          Response_ResponseType  `protobuf:"varint,1,opt,name=type,proto3,enum=control.Response_ResponseType" json:"type,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  Response_ResponseType  `protobuf:"varint,1,opt,name=type,proto3,enum=control.Response_ResponseType" json:"type,omitempty"`
+	// Leader is the endpoint answering a PING or ELECT.
+	Leader        *Leader `protobuf:"bytes,2,opt,name=Leader,proto3" json:"Leader,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -250,16 +264,98 @@ func (x *Response) GetType() Response_ResponseType {
 	return Response_OK
 }
 
+func (x *Response) GetLeader() *Leader {
+	if x != nil {
+		return x.Leader
+	}
+	return nil
+}
+
+// Leader identifies an endpoint in the leader election. The endpoint with the highest Priority,
+// then the highest ID, is preferred. Term counts the servers elected.
+type Leader struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	ID       string                 `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Priority int64                  `protobuf:"varint,2,opt,name=Priority,proto3" json:"Priority,omitempty"`
+	Term     uint64                 `protobuf:"varint,3,opt,name=Term,proto3" json:"Term,omitempty"`
+	// Standby is set by an endpoint taking part in the election that is not the server.
+	Standby       bool `protobuf:"varint,4,opt,name=Standby,proto3" json:"Standby,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Leader) Reset() {
+	*x = Leader{}
+	mi := &file_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Leader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Leader) ProtoMessage() {}
+
+func (x *Leader) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Leader.ProtoReflect.Descriptor instead.
+func (*Leader) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Leader) GetID() string {
+	if x != nil {
+		return x.ID
+	}
+	return ""
+}
+
+func (x *Leader) GetPriority() int64 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *Leader) GetTerm() uint64 {
+	if x != nil {
+		return x.Term
+	}
+	return 0
+}
+
+func (x *Leader) GetStandby() bool {
+	if x != nil {
+		return x.Standby
+	}
+	return false
+}
+
 type Request struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          Request_RequestType    `protobuf:"varint,1,opt,name=type,proto3,enum=control.Request_RequestType" json:"type,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  Request_RequestType    `protobuf:"varint,1,opt,name=type,proto3,enum=control.Request_RequestType" json:"type,omitempty"`
+	// Session and Resume name the last change set of the server the client applied, CHANGES
+	// returns the changes following it.
+	Session       string `protobuf:"bytes,2,opt,name=Session,proto3" json:"Session,omitempty"`
+	Resume        uint64 `protobuf:"varint,3,opt,name=Resume,proto3" json:"Resume,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Request) Reset() {
 	*x = Request{}
-	mi := &file_control_proto_msgTypes[2]
+	mi := &file_control_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -271,7 +367,7 @@ func (x *Request) String() string {
 func (*Request) ProtoMessage() {}
 
 func (x *Request) ProtoReflect() protoreflect.Message {
-	mi := &file_control_proto_msgTypes[2]
+	mi := &file_control_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -284,7 +380,7 @@ func (x *Request) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Request.ProtoReflect.Descriptor instead.
 func (*Request) Descriptor() ([]byte, []int) {
-	return file_control_proto_rawDescGZIP(), []int{2}
+	return file_control_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Request) GetType() Request_RequestType {
@@ -294,19 +390,40 @@ func (x *Request) GetType() Request_RequestType {
 	return Request_CHANGES
 }
 
+func (x *Request) GetSession() string {
+	if x != nil {
+		return x.Session
+	}
+	return ""
+}
+
+func (x *Request) GetResume() uint64 {
+	if x != nil {
+		return x.Resume
+	}
+	return 0
+}
+
 type Entry struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           []*Key                 `protobuf:"bytes,1,rep,name=Key,proto3" json:"Key,omitempty"`
-	KeyI          int64                  `protobuf:"varint,2,opt,name=KeyI,proto3" json:"KeyI,omitempty"`
-	Value         *Object                `protobuf:"bytes,3,opt,name=Value,proto3" json:"Value,omitempty"`
-	Remove        bool                   `protobuf:"varint,4,opt,name=Remove,proto3" json:"Remove,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Key    []*Key                 `protobuf:"bytes,1,rep,name=Key,proto3" json:"Key,omitempty"`
+	KeyI   int64                  `protobuf:"varint,2,opt,name=KeyI,proto3" json:"KeyI,omitempty"`
+	Value  *Object                `protobuf:"bytes,3,opt,name=Value,proto3" json:"Value,omitempty"`
+	Remove bool                   `protobuf:"varint,4,opt,name=Remove,proto3" json:"Remove,omitempty"`
+	// Rejected is set when the entry is returned to its sender because it was not applied, the
+	// entry then holds the value of the receiver at its path.
+	Rejected bool   `protobuf:"varint,5,opt,name=Rejected,proto3" json:"Rejected,omitempty"`
+	Reason   string `protobuf:"bytes,6,opt,name=Reason,proto3" json:"Reason,omitempty"`
+	// Clock is the Lamport timestamp of the write and Origin the ID of the endpoint that made it.
+	Clock         uint64 `protobuf:"varint,7,opt,name=Clock,proto3" json:"Clock,omitempty"`
+	Origin        string `protobuf:"bytes,8,opt,name=Origin,proto3" json:"Origin,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Entry) Reset() {
 	*x = Entry{}
-	mi := &file_control_proto_msgTypes[3]
+	mi := &file_control_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -318,7 +435,7 @@ func (x *Entry) String() string {
 func (*Entry) ProtoMessage() {}
 
 func (x *Entry) ProtoReflect() protoreflect.Message {
-	mi := &file_control_proto_msgTypes[3]
+	mi := &file_control_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -331,7 +448,7 @@ func (x *Entry) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Entry.ProtoReflect.Descriptor instead.
 func (*Entry) Descriptor() ([]byte, []int) {
-	return file_control_proto_rawDescGZIP(), []int{3}
+	return file_control_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Entry) GetKey() []*Key {
@@ -362,6 +479,350 @@ func (x *Entry) GetRemove() bool {
 	return false
 }
 
+func (x *Entry) GetRejected() bool {
+	if x != nil {
+		return x.Rejected
+	}
+	return false
+}
+
+func (x *Entry) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Entry) GetClock() uint64 {
+	if x != nil {
+		return x.Clock
+	}
+	return 0
+}
+
+func (x *Entry) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+// ChangeSet groups the entries of one extraction, they are applied all together or not at all.
+//
+// Origin is the ID of the endpoint that extracted the change set and Clock the time of its clock
+// the entries were stamped with, together they identify the change set in a mesh. Route lists the
+// endpoints that forwarded it, in order, its length is the number of hops.
+type ChangeSet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*Entry               `protobuf:"bytes,1,rep,name=Entries,proto3" json:"Entries,omitempty"`
+	Origin        string                 `protobuf:"bytes,2,opt,name=Origin,proto3" json:"Origin,omitempty"`
+	Clock         uint64                 `protobuf:"varint,3,opt,name=Clock,proto3" json:"Clock,omitempty"`
+	Route         []string               `protobuf:"bytes,4,rep,name=Route,proto3" json:"Route,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChangeSet) Reset() {
+	*x = ChangeSet{}
+	mi := &file_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChangeSet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangeSet) ProtoMessage() {}
+
+func (x *ChangeSet) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChangeSet.ProtoReflect.Descriptor instead.
+func (*ChangeSet) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ChangeSet) GetEntries() []*Entry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *ChangeSet) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *ChangeSet) GetClock() uint64 {
+	if x != nil {
+		return x.Clock
+	}
+	return 0
+}
+
+func (x *ChangeSet) GetRoute() []string {
+	if x != nil {
+		return x.Route
+	}
+	return nil
+}
+
+// Frame is a numbered change set sent on the PushPull stream. A frame with a Seq is acknowledged
+// with a frame with the same Ack once its change set is applied, the acknowledgement holds the
+// entries that were rejected set back to the data of the receiver.
+//
+// The first frame of a client names the Session of the server and the last Seq of it the client
+// applied in Resume, the server continues with the change sets following it. When the server no
+// longer holds them it sends a Snapshot of the entire data with the Session it belongs to. The
+// first frame also names the ID of the endpoint of the client in Origin, the change sets it sent
+// are not sent back to it, not even on a new stream.
+//
+// In a mesh the first frame of both sides also names the ID of their endpoint in Peer.
+type Frame struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           uint64                 `protobuf:"varint,1,opt,name=Seq,proto3" json:"Seq,omitempty"`
+	Changes       *ChangeSet             `protobuf:"bytes,2,opt,name=Changes,proto3" json:"Changes,omitempty"`
+	Ack           uint64                 `protobuf:"varint,3,opt,name=Ack,proto3" json:"Ack,omitempty"`
+	Rejected      []*Entry               `protobuf:"bytes,4,rep,name=Rejected,proto3" json:"Rejected,omitempty"`
+	Session       string                 `protobuf:"bytes,5,opt,name=Session,proto3" json:"Session,omitempty"`
+	Resume        uint64                 `protobuf:"varint,6,opt,name=Resume,proto3" json:"Resume,omitempty"`
+	Snapshot      bool                   `protobuf:"varint,7,opt,name=Snapshot,proto3" json:"Snapshot,omitempty"`
+	Peer          string                 `protobuf:"bytes,8,opt,name=Peer,proto3" json:"Peer,omitempty"`
+	Origin        string                 `protobuf:"bytes,9,opt,name=Origin,proto3" json:"Origin,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Frame) Reset() {
+	*x = Frame{}
+	mi := &file_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Frame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Frame) ProtoMessage() {}
+
+func (x *Frame) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Frame.ProtoReflect.Descriptor instead.
+func (*Frame) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Frame) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Frame) GetChanges() *ChangeSet {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *Frame) GetAck() uint64 {
+	if x != nil {
+		return x.Ack
+	}
+	return 0
+}
+
+func (x *Frame) GetRejected() []*Entry {
+	if x != nil {
+		return x.Rejected
+	}
+	return nil
+}
+
+func (x *Frame) GetSession() string {
+	if x != nil {
+		return x.Session
+	}
+	return ""
+}
+
+func (x *Frame) GetResume() uint64 {
+	if x != nil {
+		return x.Resume
+	}
+	return 0
+}
+
+func (x *Frame) GetSnapshot() bool {
+	if x != nil {
+		return x.Snapshot
+	}
+	return false
+}
+
+func (x *Frame) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *Frame) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+// Digest is the hash of the subtree of the data at Path, the root has the empty path. Children
+// holds the digests of the fields, indexes and map keys right below it.
+type Digest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	Hash          []byte                 `protobuf:"bytes,2,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	Children      []*Digest              `protobuf:"bytes,3,rep,name=Children,proto3" json:"Children,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Digest) Reset() {
+	*x = Digest{}
+	mi := &file_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Digest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Digest) ProtoMessage() {}
+
+func (x *Digest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Digest.ProtoReflect.Descriptor instead.
+func (*Digest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Digest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Digest) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Digest) GetChildren() []*Digest {
+	if x != nil {
+		return x.Children
+	}
+	return nil
+}
+
+// Digests asks for the digests of the subtrees at the paths of Digests and answers with them. The
+// answer names the Session of the server and the Seq of its last change set the digests are of.
+type Digests struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Digests       []*Digest              `protobuf:"bytes,1,rep,name=Digests,proto3" json:"Digests,omitempty"`
+	Session       string                 `protobuf:"bytes,2,opt,name=Session,proto3" json:"Session,omitempty"`
+	Seq           uint64                 `protobuf:"varint,3,opt,name=Seq,proto3" json:"Seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Digests) Reset() {
+	*x = Digests{}
+	mi := &file_control_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Digests) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Digests) ProtoMessage() {}
+
+func (x *Digests) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Digests.ProtoReflect.Descriptor instead.
+func (*Digests) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Digests) GetDigests() []*Digest {
+	if x != nil {
+		return x.Digests
+	}
+	return nil
+}
+
+func (x *Digests) GetSession() string {
+	if x != nil {
+		return x.Session
+	}
+	return ""
+}
+
+func (x *Digests) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
 type Key struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           string                 `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
@@ -373,7 +834,7 @@ type Key struct {
 
 func (x *Key) Reset() {
 	*x = Key{}
-	mi := &file_control_proto_msgTypes[4]
+	mi := &file_control_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -385,7 +846,7 @@ func (x *Key) String() string {
 func (*Key) ProtoMessage() {}
 
 func (x *Key) ProtoReflect() protoreflect.Message {
-	mi := &file_control_proto_msgTypes[4]
+	mi := &file_control_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -398,7 +859,7 @@ func (x *Key) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Key.ProtoReflect.Descriptor instead.
 func (*Key) Descriptor() ([]byte, []int) {
-	return file_control_proto_rawDescGZIP(), []int{4}
+	return file_control_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *Key) GetKey() string {
@@ -437,7 +898,7 @@ type Object struct {
 
 func (x *Object) Reset() {
 	*x = Object{}
-	mi := &file_control_proto_msgTypes[5]
+	mi := &file_control_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -449,7 +910,7 @@ func (x *Object) String() string {
 func (*Object) ProtoMessage() {}
 
 func (x *Object) ProtoReflect() protoreflect.Message {
-	mi := &file_control_proto_msgTypes[5]
+	mi := &file_control_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -462,7 +923,7 @@ func (x *Object) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Object.ProtoReflect.Descriptor instead.
 func (*Object) Descriptor() ([]byte, []int) {
-	return file_control_proto_rawDescGZIP(), []int{5}
+	return file_control_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *Object) GetString_() string {
@@ -518,29 +979,66 @@ var File_control_proto protoreflect.FileDescriptor
 
 const file_control_proto_rawDesc = "" +
 	"\n" +
-	"\rcontrol.proto\x12\acontrol\"d\n" +
+	"\rcontrol.proto\x12\acontrol\"\x9e\x01\n" +
 	"\aMessage\x123\n" +
-	"\x06action\x18\x01 \x01(\x0e2\x1b.control.Message.ActionTypeR\x06action\"$\n" +
+	"\x06action\x18\x01 \x01(\x0e2\x1b.control.Message.ActionTypeR\x06action\x12-\n" +
+	"\tCandidate\x18\x02 \x01(\v2\x0f.control.LeaderR\tCandidate\"/\n" +
 	"\n" +
 	"ActionType\x12\b\n" +
 	"\x04PING\x10\x00\x12\f\n" +
-	"\bSHUTDOWN\x10\x01\"a\n" +
+	"\bSHUTDOWN\x10\x01\x12\t\n" +
+	"\x05ELECT\x10\x02\"\x8a\x01\n" +
 	"\bResponse\x122\n" +
-	"\x04type\x18\x01 \x01(\x0e2\x1e.control.Response.ResponseTypeR\x04type\"!\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1e.control.Response.ResponseTypeR\x04type\x12'\n" +
+	"\x06Leader\x18\x02 \x01(\v2\x0f.control.LeaderR\x06Leader\"!\n" +
 	"\fResponseType\x12\x06\n" +
 	"\x02OK\x10\x00\x12\t\n" +
-	"\x05ERROR\x10\x01\"o\n" +
+	"\x05ERROR\x10\x01\"b\n" +
+	"\x06Leader\x12\x0e\n" +
+	"\x02ID\x18\x01 \x01(\tR\x02ID\x12\x1a\n" +
+	"\bPriority\x18\x02 \x01(\x03R\bPriority\x12\x12\n" +
+	"\x04Term\x18\x03 \x01(\x04R\x04Term\x12\x18\n" +
+	"\aStandby\x18\x04 \x01(\bR\aStandby\"\xa1\x01\n" +
 	"\aRequest\x120\n" +
-	"\x04type\x18\x01 \x01(\x0e2\x1c.control.Request.RequestTypeR\x04type\"2\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1c.control.Request.RequestTypeR\x04type\x12\x18\n" +
+	"\aSession\x18\x02 \x01(\tR\aSession\x12\x16\n" +
+	"\x06Resume\x18\x03 \x01(\x04R\x06Resume\"2\n" +
 	"\vRequestType\x12\v\n" +
 	"\aCHANGES\x10\x00\x12\b\n" +
 	"\x04INIT\x10\x01\x12\f\n" +
-	"\bSETTINGS\x10\x02\"z\n" +
+	"\bSETTINGS\x10\x02\"\xdc\x01\n" +
 	"\x05Entry\x12\x1e\n" +
 	"\x03Key\x18\x01 \x03(\v2\f.control.KeyR\x03Key\x12\x12\n" +
 	"\x04KeyI\x18\x02 \x01(\x03R\x04KeyI\x12%\n" +
 	"\x05Value\x18\x03 \x01(\v2\x0f.control.ObjectR\x05Value\x12\x16\n" +
-	"\x06Remove\x18\x04 \x01(\bR\x06Remove\"V\n" +
+	"\x06Remove\x18\x04 \x01(\bR\x06Remove\x12\x1a\n" +
+	"\bRejected\x18\x05 \x01(\bR\bRejected\x12\x16\n" +
+	"\x06Reason\x18\x06 \x01(\tR\x06Reason\x12\x14\n" +
+	"\x05Clock\x18\a \x01(\x04R\x05Clock\x12\x16\n" +
+	"\x06Origin\x18\b \x01(\tR\x06Origin\"y\n" +
+	"\tChangeSet\x12(\n" +
+	"\aEntries\x18\x01 \x03(\v2\x0e.control.EntryR\aEntries\x12\x16\n" +
+	"\x06Origin\x18\x02 \x01(\tR\x06Origin\x12\x14\n" +
+	"\x05Clock\x18\x03 \x01(\x04R\x05Clock\x12\x14\n" +
+	"\x05Route\x18\x04 \x03(\tR\x05Route\"\xff\x01\n" +
+	"\x05Frame\x12\x10\n" +
+	"\x03Seq\x18\x01 \x01(\x04R\x03Seq\x12,\n" +
+	"\aChanges\x18\x02 \x01(\v2\x12.control.ChangeSetR\aChanges\x12\x10\n" +
+	"\x03Ack\x18\x03 \x01(\x04R\x03Ack\x12*\n" +
+	"\bRejected\x18\x04 \x03(\v2\x0e.control.EntryR\bRejected\x12\x18\n" +
+	"\aSession\x18\x05 \x01(\tR\aSession\x12\x16\n" +
+	"\x06Resume\x18\x06 \x01(\x04R\x06Resume\x12\x1a\n" +
+	"\bSnapshot\x18\a \x01(\bR\bSnapshot\x12\x12\n" +
+	"\x04Peer\x18\b \x01(\tR\x04Peer\x12\x16\n" +
+	"\x06Origin\x18\t \x01(\tR\x06Origin\"]\n" +
+	"\x06Digest\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x12\n" +
+	"\x04Hash\x18\x02 \x01(\fR\x04Hash\x12+\n" +
+	"\bChildren\x18\x03 \x03(\v2\x0f.control.DigestR\bChildren\"`\n" +
+	"\aDigests\x12)\n" +
+	"\aDigests\x18\x01 \x03(\v2\x0f.control.DigestR\aDigests\x12\x18\n" +
+	"\aSession\x18\x02 \x01(\tR\aSession\x12\x10\n" +
+	"\x03Seq\x18\x03 \x01(\x04R\x03Seq\"V\n" +
 	"\x03Key\x12\x10\n" +
 	"\x03Key\x18\x01 \x01(\tR\x03Key\x12%\n" +
 	"\x05Index\x18\x02 \x03(\v2\x0f.control.ObjectR\x05Index\x12\x16\n" +
@@ -561,12 +1059,14 @@ const file_control_proto_rawDesc = "" +
 	"\n" +
 	"\b_float64B\a\n" +
 	"\x05_boolB\b\n" +
-	"\x06_bytes2\xca\x01\n" +
+	"\x06_bytes2\xac\x02\n" +
 	"\aControl\x12,\n" +
 	"\x04Pull\x12\x10.control.Request\x1a\x0e.control.Entry\"\x000\x01\x12-\n" +
 	"\x04Push\x12\x0e.control.Entry\x1a\x11.control.Response\"\x00(\x01\x120\n" +
-	"\bPushPull\x12\x0e.control.Entry\x1a\x0e.control.Entry\"\x00(\x010\x01\x120\n" +
-	"\aControl\x12\x10.control.Message\x1a\x11.control.Response\"\x00B\n" +
+	"\bPushPull\x12\x0e.control.Frame\x1a\x0e.control.Frame\"\x00(\x010\x01\x120\n" +
+	"\aControl\x12\x10.control.Message\x1a\x11.control.Response\"\x00\x12.\n" +
+	"\x06Digest\x12\x10.control.Digests\x1a\x10.control.Digests\"\x00\x120\n" +
+	"\bSubtrees\x12\x10.control.Digests\x1a\x0e.control.Entry\"\x000\x01B\n" +
 	"Z\b/controlb\x06proto3"
 
 var (
@@ -582,38 +1082,54 @@ func file_control_proto_rawDescGZIP() []byte {
 }
 
 var file_control_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_control_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_control_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_control_proto_goTypes = []any{
 	(Message_ActionType)(0),    // 0: control.Message.ActionType
 	(Response_ResponseType)(0), // 1: control.Response.ResponseType
 	(Request_RequestType)(0),   // 2: control.Request.RequestType
 	(*Message)(nil),            // 3: control.Message
 	(*Response)(nil),           // 4: control.Response
-	(*Request)(nil),            // 5: control.Request
-	(*Entry)(nil),              // 6: control.Entry
-	(*Key)(nil),                // 7: control.Key
-	(*Object)(nil),             // 8: control.Object
+	(*Leader)(nil),             // 5: control.Leader
+	(*Request)(nil),            // 6: control.Request
+	(*Entry)(nil),              // 7: control.Entry
+	(*ChangeSet)(nil),          // 8: control.ChangeSet
+	(*Frame)(nil),              // 9: control.Frame
+	(*Digest)(nil),             // 10: control.Digest
+	(*Digests)(nil),            // 11: control.Digests
+	(*Key)(nil),                // 12: control.Key
+	(*Object)(nil),             // 13: control.Object
 }
 var file_control_proto_depIdxs = []int32{
 	0,  // 0: control.Message.action:type_name -> control.Message.ActionType
-	1,  // 1: control.Response.type:type_name -> control.Response.ResponseType
-	2,  // 2: control.Request.type:type_name -> control.Request.RequestType
-	7,  // 3: control.Entry.Key:type_name -> control.Key
-	8,  // 4: control.Entry.Value:type_name -> control.Object
-	8,  // 5: control.Key.Index:type_name -> control.Object
-	5,  // 6: control.Control.Pull:input_type -> control.Request
-	6,  // 7: control.Control.Push:input_type -> control.Entry
-	6,  // 8: control.Control.PushPull:input_type -> control.Entry
-	3,  // 9: control.Control.Control:input_type -> control.Message
-	6,  // 10: control.Control.Pull:output_type -> control.Entry
-	4,  // 11: control.Control.Push:output_type -> control.Response
-	6,  // 12: control.Control.PushPull:output_type -> control.Entry
-	4,  // 13: control.Control.Control:output_type -> control.Response
-	10, // [10:14] is the sub-list for method output_type
-	6,  // [6:10] is the sub-list for method input_type
-	6,  // [6:6] is the sub-list for extension type_name
-	6,  // [6:6] is the sub-list for extension extendee
-	0,  // [0:6] is the sub-list for field type_name
+	5,  // 1: control.Message.Candidate:type_name -> control.Leader
+	1,  // 2: control.Response.type:type_name -> control.Response.ResponseType
+	5,  // 3: control.Response.Leader:type_name -> control.Leader
+	2,  // 4: control.Request.type:type_name -> control.Request.RequestType
+	12, // 5: control.Entry.Key:type_name -> control.Key
+	13, // 6: control.Entry.Value:type_name -> control.Object
+	7,  // 7: control.ChangeSet.Entries:type_name -> control.Entry
+	8,  // 8: control.Frame.Changes:type_name -> control.ChangeSet
+	7,  // 9: control.Frame.Rejected:type_name -> control.Entry
+	10, // 10: control.Digest.Children:type_name -> control.Digest
+	10, // 11: control.Digests.Digests:type_name -> control.Digest
+	13, // 12: control.Key.Index:type_name -> control.Object
+	6,  // 13: control.Control.Pull:input_type -> control.Request
+	7,  // 14: control.Control.Push:input_type -> control.Entry
+	9,  // 15: control.Control.PushPull:input_type -> control.Frame
+	3,  // 16: control.Control.Control:input_type -> control.Message
+	11, // 17: control.Control.Digest:input_type -> control.Digests
+	11, // 18: control.Control.Subtrees:input_type -> control.Digests
+	7,  // 19: control.Control.Pull:output_type -> control.Entry
+	4,  // 20: control.Control.Push:output_type -> control.Response
+	9,  // 21: control.Control.PushPull:output_type -> control.Frame
+	4,  // 22: control.Control.Control:output_type -> control.Response
+	11, // 23: control.Control.Digest:output_type -> control.Digests
+	7,  // 24: control.Control.Subtrees:output_type -> control.Entry
+	19, // [19:25] is the sub-list for method output_type
+	13, // [13:19] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_control_proto_init() }
@@ -621,14 +1137,14 @@ func file_control_proto_init() {
 	if File_control_proto != nil {
 		return
 	}
-	file_control_proto_msgTypes[5].OneofWrappers = []any{}
+	file_control_proto_msgTypes[10].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)),
 			NumEnums:      3,
-			NumMessages:   6,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -29,6 +29,15 @@ func (e *Entry) Advance() *Entry {
 	return e
 }
 
+// Rewind moves the key cursor advanced by Advance back to the first key.
+func (e *Entry) Rewind() *Entry {
+	e.KeyI = 0
+	for _, k := range e.GetKey() {
+		k.IndexI = 0
+	}
+	return e
+}
+
 func (e *Entry) IsLastKeyIndex() bool {
 	return len(e.GetKey()) == 0 || (int(e.KeyI) == len(e.GetKey())-1 && e.GetCurrKey().IsLastIndex())
 }
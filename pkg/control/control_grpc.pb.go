@@ -23,6 +23,8 @@ const (
 	Control_Push_FullMethodName     = "/control.Control/Push"
 	Control_PushPull_FullMethodName = "/control.Control/PushPull"
 	Control_Control_FullMethodName  = "/control.Control/Control"
+	Control_Digest_FullMethodName   = "/control.Control/Digest"
+	Control_Subtrees_FullMethodName = "/control.Control/Subtrees"
 )
 
 // ControlClient is the client API for Control service.
@@ -31,8 +33,10 @@ const (
 type ControlClient interface {
 	Pull(ctx context.Context, in *Request, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Entry], error)
 	Push(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Entry, Response], error)
-	PushPull(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Entry, Entry], error)
+	PushPull(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Frame, Frame], error)
 	Control(ctx context.Context, in *Message, opts ...grpc.CallOption) (*Response, error)
+	Digest(ctx context.Context, in *Digests, opts ...grpc.CallOption) (*Digests, error)
+	Subtrees(ctx context.Context, in *Digests, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Entry], error)
 }
 
 type controlClient struct {
@@ -75,18 +79,18 @@ func (c *controlClient) Push(ctx context.Context, opts ...grpc.CallOption) (grpc
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Control_PushClient = grpc.ClientStreamingClient[Entry, Response]
 
-func (c *controlClient) PushPull(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Entry, Entry], error) {
+func (c *controlClient) PushPull(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Frame, Frame], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &Control_ServiceDesc.Streams[2], Control_PushPull_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &grpc.GenericClientStream[Entry, Entry]{ClientStream: stream}
+	x := &grpc.GenericClientStream[Frame, Frame]{ClientStream: stream}
 	return x, nil
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
-type Control_PushPullClient = grpc.BidiStreamingClient[Entry, Entry]
+type Control_PushPullClient = grpc.BidiStreamingClient[Frame, Frame]
 
 func (c *controlClient) Control(ctx context.Context, in *Message, opts ...grpc.CallOption) (*Response, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
@@ -98,14 +102,45 @@ func (c *controlClient) Control(ctx context.Context, in *Message, opts ...grpc.C
 	return out, nil
 }
 
+func (c *controlClient) Digest(ctx context.Context, in *Digests, opts ...grpc.CallOption) (*Digests, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Digests)
+	err := c.cc.Invoke(ctx, Control_Digest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) Subtrees(ctx context.Context, in *Digests, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Entry], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Control_ServiceDesc.Streams[3], Control_Subtrees_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Digests, Entry]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Control_SubtreesClient = grpc.ServerStreamingClient[Entry]
+
 // ControlServer is the server API for Control service.
 // All implementations must embed UnimplementedControlServer
 // for forward compatibility.
 type ControlServer interface {
 	Pull(*Request, grpc.ServerStreamingServer[Entry]) error
 	Push(grpc.ClientStreamingServer[Entry, Response]) error
-	PushPull(grpc.BidiStreamingServer[Entry, Entry]) error
+	PushPull(grpc.BidiStreamingServer[Frame, Frame]) error
 	Control(context.Context, *Message) (*Response, error)
+	Digest(context.Context, *Digests) (*Digests, error)
+	Subtrees(*Digests, grpc.ServerStreamingServer[Entry]) error
 	mustEmbedUnimplementedControlServer()
 }
 
@@ -122,12 +157,18 @@ func (UnimplementedControlServer) Pull(*Request, grpc.ServerStreamingServer[Entr
 func (UnimplementedControlServer) Push(grpc.ClientStreamingServer[Entry, Response]) error {
 	return status.Errorf(codes.Unimplemented, "method Push not implemented")
 }
-func (UnimplementedControlServer) PushPull(grpc.BidiStreamingServer[Entry, Entry]) error {
+func (UnimplementedControlServer) PushPull(grpc.BidiStreamingServer[Frame, Frame]) error {
 	return status.Errorf(codes.Unimplemented, "method PushPull not implemented")
 }
 func (UnimplementedControlServer) Control(context.Context, *Message) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Control not implemented")
 }
+func (UnimplementedControlServer) Digest(context.Context, *Digests) (*Digests, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Digest not implemented")
+}
+func (UnimplementedControlServer) Subtrees(*Digests, grpc.ServerStreamingServer[Entry]) error {
+	return status.Errorf(codes.Unimplemented, "method Subtrees not implemented")
+}
 func (UnimplementedControlServer) mustEmbedUnimplementedControlServer() {}
 func (UnimplementedControlServer) testEmbeddedByValue()                 {}
 
@@ -168,11 +209,11 @@ func _Control_Push_Handler(srv interface{}, stream grpc.ServerStream) error {
 type Control_PushServer = grpc.ClientStreamingServer[Entry, Response]
 
 func _Control_PushPull_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(ControlServer).PushPull(&grpc.GenericServerStream[Entry, Entry]{ServerStream: stream})
+	return srv.(ControlServer).PushPull(&grpc.GenericServerStream[Frame, Frame]{ServerStream: stream})
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
-type Control_PushPullServer = grpc.BidiStreamingServer[Entry, Entry]
+type Control_PushPullServer = grpc.BidiStreamingServer[Frame, Frame]
 
 func _Control_Control_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Message)
@@ -192,6 +233,35 @@ func _Control_Control_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Control_Digest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Digests)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).Digest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_Digest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).Digest(ctx, req.(*Digests))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_Subtrees_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Digests)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ControlServer).Subtrees(m, &grpc.GenericServerStream[Digests, Entry]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Control_SubtreesServer = grpc.ServerStreamingServer[Entry]
+
 // Control_ServiceDesc is the grpc.ServiceDesc for Control service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -203,6 +273,10 @@ var Control_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Control",
 			Handler:    _Control_Control_Handler,
 		},
+		{
+			MethodName: "Digest",
+			Handler:    _Control_Digest_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -221,6 +295,11 @@ var Control_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Subtrees",
+			Handler:       _Control_Subtrees_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "control.proto",
 }
@@ -0,0 +1,9 @@
+package control
+
+// Outranks reports if l is preferred over other as the server, by Priority and then ID.
+func (l *Leader) Outranks(other *Leader) bool {
+	if l.GetPriority() != other.GetPriority() {
+		return l.GetPriority() > other.GetPriority()
+	}
+	return l.GetID() > other.GetID()
+}
@@ -163,6 +163,28 @@ func NewObject(v any) *Object {
 	return &o
 }
 
+// Zero returns an object holding the zero value of the kind of value o holds, bytes are empty.
+func (o *Object) Zero() *Object {
+	switch {
+	case o == nil:
+		return nil
+	case o.String_ != nil:
+		return &Object{String_: MakePtr("")}
+	case o.Int64 != nil:
+		return &Object{Int64: MakePtr(int64(0))}
+	case o.Uint64 != nil:
+		return &Object{Uint64: MakePtr(uint64(0))}
+	case o.Float32 != nil:
+		return &Object{Float32: MakePtr(float32(0))}
+	case o.Float64 != nil:
+		return &Object{Float64: MakePtr(float64(0))}
+	case o.Bool != nil:
+		return &Object{Bool: MakePtr(false)}
+	default:
+		return &Object{Bytes: []byte{}}
+	}
+}
+
 func (o *Object) SetValue(va reflect.Value) error {
 	switch va.Kind() {
 	case reflect.String:
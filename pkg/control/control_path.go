@@ -0,0 +1,229 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPattern = errors.New("invalid path pattern")
+
+// wildcard matches any field name or index in a Pattern.
+const wildcard = "*"
+
+// pathToken is a single step in a path, either a field name or an index.
+type pathToken struct {
+	value string
+	index bool
+}
+
+// Pattern matches the path of entries. A pattern is written like a path, fields are separated by a
+// dot and indexes are enclosed in brackets. A * matches any single field or index and [*] any
+// single index, e.g. Config.Limits.* or Map[*].Status.
+// A pattern covers everything below the path it describes.
+type Pattern struct {
+	raw    string
+	tokens []pathToken
+}
+
+// NewPattern parses a pattern.
+func NewPattern(pattern string) (*Pattern, error) {
+	tokens, err := parsePath(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Pattern{raw: pattern, tokens: tokens}, nil
+}
+
+// String returns the pattern as it was given.
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Len returns the number of fields and indexes in the pattern.
+func (p *Pattern) Len() int {
+	return len(p.tokens)
+}
+
+// Wildcards returns the number of wildcards in the pattern.
+func (p *Pattern) Wildcards() int {
+	n := 0
+	for _, t := range p.tokens {
+		if t.value == wildcard {
+			n++
+		}
+	}
+	return n
+}
+
+// Match reports if the entry is at or below the path described by the pattern.
+func (p *Pattern) Match(e *Entry) bool {
+	tokens := e.pathTokens()
+	return len(p.tokens) <= len(tokens) && matchTokens(p.tokens, tokens)
+}
+
+// Covered reports if the entry is above the path described by the pattern, applying the entry
+// replaces everything the pattern matches.
+func (p *Pattern) Covered(e *Entry) bool {
+	tokens := e.pathTokens()
+	return len(tokens) < len(p.tokens) && matchTokens(p.tokens, tokens)
+}
+
+// Overlaps reports if applying the entry changes anything the pattern matches.
+func (p *Pattern) Overlaps(e *Entry) bool {
+	return matchTokens(p.tokens, e.pathTokens())
+}
+
+// Path returns the path of the entry without the top level type, fields are separated by a dot
+// and indexes are enclosed in brackets, e.g. Config.Limits[max].Value.
+func (e *Entry) Path() string {
+	var sb strings.Builder
+	for _, t := range e.pathTokens() {
+		if t.index {
+			sb.WriteString("[" + t.value + "]")
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(t.value)
+	}
+	return sb.String()
+}
+
+// Paths returns the path of every subtree the entry is in from the top down, the last one is the
+// path of the entry, e.g. Map, Map[a] and Map[a].Status.
+func (e *Entry) Paths() []string {
+	var sb strings.Builder
+	tokens := e.pathTokens()
+	paths := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if t.index {
+			sb.WriteString("[" + t.value + "]")
+		} else {
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(t.value)
+		}
+		paths = append(paths, sb.String())
+	}
+	return paths
+}
+
+// Ancestor returns an entry without a value for the subtree the entry is in at depth, the number
+// of fields and indexes of its path. Depth zero is the entire data.
+func (e *Entry) Ancestor(depth int) *Entry {
+	a := &Entry{}
+	n := 0
+	for i, k := range e.GetKey() {
+		if i > 0 && n == depth {
+			break
+		}
+		key := &Key{Key: k.GetKey()}
+		if i > 0 && k.GetKey() != "" {
+			n++
+		}
+		for _, idx := range k.GetIndex() {
+			if n == depth {
+				break
+			}
+			key.Index = append(key.Index, idx)
+			n++
+		}
+		a.Key = append(a.Key, key)
+	}
+	return a
+}
+
+// pathTokens flattens the keys of the entry, the first key is the top level type and skipped.
+func (e *Entry) pathTokens() []pathToken {
+	keys := e.GetKey()
+	if len(keys) == 0 {
+		return nil
+	}
+	tokens := make([]pathToken, 0, len(keys))
+	for i, k := range keys {
+		if i > 0 && k.GetKey() != "" {
+			tokens = append(tokens, pathToken{value: k.GetKey()})
+		}
+		for _, idx := range k.GetIndex() {
+			tokens = append(tokens, pathToken{value: indexString(idx), index: true})
+		}
+	}
+	return tokens
+}
+
+// matchTokens compares the pattern and path up to the shorter of the two.
+func matchTokens(pattern, path []pathToken) bool {
+	for i := 0; i < len(pattern) && i < len(path); i++ {
+		p := pattern[i]
+		if p.value == wildcard && (!p.index || path[i].index) {
+			continue
+		}
+		if p.value != path[i].value || p.index != path[i].index {
+			return false
+		}
+	}
+	return true
+}
+
+func parsePath(path string) ([]pathToken, error) {
+	var tokens []pathToken
+	var name strings.Builder
+	flush := func() {
+		if name.Len() > 0 {
+			tokens = append(tokens, pathToken{value: name.String()})
+			name.Reset()
+		}
+	}
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '.':
+			if name.Len() == 0 && (i == 0 || path[i-1] != ']') {
+				return nil, fmt.Errorf("%w: empty field in %q", ErrInvalidPattern, path)
+			}
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unclosed index in %q", ErrInvalidPattern, path)
+			}
+			tokens = append(tokens, pathToken{value: path[i+1 : i+end], index: true})
+			i += end
+		case ']':
+			return nil, fmt.Errorf("%w: unexpected ] in %q", ErrInvalidPattern, path)
+		default:
+			name.WriteByte(path[i])
+		}
+	}
+	flush()
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty pattern", ErrInvalidPattern)
+	}
+	return tokens, nil
+}
+
+// indexString formats an index object the way it is written in a path.
+func indexString(o *Object) string {
+	switch {
+	case o == nil:
+		return ""
+	case o.String_ != nil:
+		return o.GetString_()
+	case o.Int64 != nil:
+		return strconv.FormatInt(o.GetInt64(), 10)
+	case o.Uint64 != nil:
+		return strconv.FormatUint(o.GetUint64(), 10)
+	case o.Float32 != nil:
+		return strconv.FormatFloat(float64(o.GetFloat32()), 'g', -1, 32)
+	case o.Float64 != nil:
+		return strconv.FormatFloat(o.GetFloat64(), 'g', -1, 64)
+	case o.Bool != nil:
+		return strconv.FormatBool(o.GetBool())
+	default:
+		return fmt.Sprintf("%x", o.GetBytes())
+	}
+}
@@ -0,0 +1,116 @@
+package control
+
+import (
+	"errors"
+	"testing"
+)
+
+func pathEntry(keys ...*Key) *Entry {
+	return &Entry{Key: append([]*Key{{Key: "root"}}, keys...)}
+}
+
+func TestEntry_Path(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *Entry
+		want  string
+	}{
+		{
+			name:  "field",
+			entry: pathEntry(&Key{Key: "Config"}, &Key{Key: "Name"}),
+			want:  "Config.Name",
+		},
+		{
+			name:  "map index",
+			entry: pathEntry(&Key{Key: "Map", Index: NewObjects("a")}, &Key{Key: "Status"}),
+			want:  "Map[a].Status",
+		},
+		{
+			name:  "nested index",
+			entry: pathEntry(&Key{Key: "SliceSlice", Index: NewObjects(1, NewObject(2))}),
+			want:  "SliceSlice[1][2]",
+		},
+		{
+			name:  "root",
+			entry: pathEntry(),
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.Path(); got != tt.want {
+				t.Errorf("Path() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntry_PathsAndAncestor(t *testing.T) {
+	e := pathEntry(&Key{Key: "Map", Index: NewObjects("a", NewObject(1))}, &Key{Key: "Status"})
+	paths := e.Paths()
+	want := []string{"Map", "Map[a]", "Map[a][1]", "Map[a][1].Status"}
+	if len(paths) != len(want) {
+		t.Fatalf("Paths() = %q, want %q", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("Paths() = %q, want %q", paths, want)
+		}
+		if got := e.Ancestor(i + 1).Path(); got != want[i] {
+			t.Errorf("Ancestor(%d).Path() = %q, want %q", i+1, got, want[i])
+		}
+	}
+	if got := e.Ancestor(0); len(got.GetKey()) != 1 || got.GetKey()[0].GetKey() != "root" {
+		t.Errorf("Ancestor(0) = %v, want the root key only", got)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	limitsMax := pathEntry(&Key{Key: "Config"}, &Key{Key: "Limits"}, &Key{Key: "Max"})
+	config := pathEntry(&Key{Key: "Config"})
+	mapStatus := pathEntry(&Key{Key: "Map", Index: NewObjects("a")}, &Key{Key: "Status"})
+	mapName := pathEntry(&Key{Key: "Map", Index: NewObjects("a")}, &Key{Key: "Name"})
+
+	tests := []struct {
+		pattern     string
+		entry       *Entry
+		match       bool
+		covered     bool
+		overlapping bool
+	}{
+		{pattern: "Config.Limits.*", entry: limitsMax, match: true, overlapping: true},
+		{pattern: "Config.Limits", entry: limitsMax, match: true, overlapping: true},
+		{pattern: "Config.Limits.*", entry: config, covered: true, overlapping: true},
+		{pattern: "Config.Other", entry: limitsMax},
+		{pattern: "Map[*].Status", entry: mapStatus, match: true, overlapping: true},
+		{pattern: "Map[*].Status", entry: mapName},
+		{pattern: "Map[b]", entry: mapStatus},
+		{pattern: "*", entry: mapName, match: true, overlapping: true},
+		{pattern: "[*]", entry: mapName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.entry.Path(), func(t *testing.T) {
+			p, err := NewPattern(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Match(tt.entry); got != tt.match {
+				t.Errorf("Match() = %v, want %v", got, tt.match)
+			}
+			if got := p.Covered(tt.entry); got != tt.covered {
+				t.Errorf("Covered() = %v, want %v", got, tt.covered)
+			}
+			if got := p.Overlaps(tt.entry); got != tt.overlapping {
+				t.Errorf("Overlaps() = %v, want %v", got, tt.overlapping)
+			}
+		})
+	}
+}
+
+func TestNewPattern_Invalid(t *testing.T) {
+	for _, pattern := range []string{"", "Config..Name", "Map[a", "Map]"} {
+		if _, err := NewPattern(pattern); !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("NewPattern(%q) error = %v, want ErrInvalidPattern", pattern, err)
+		}
+	}
+}
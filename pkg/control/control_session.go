@@ -0,0 +1,8 @@
+package control
+
+// Keys of the trailer of a Pull stream, they hold the session of the server and the sequence number
+// of its last change set included in the stream. The next Request resumes from them.
+const (
+	SessionKey = "syncer-session"
+	SeqKey     = "syncer-seq"
+)
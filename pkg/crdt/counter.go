@@ -0,0 +1,91 @@
+package crdt
+
+import (
+	"encoding/json"
+)
+
+// GCounter is a grow-only counter, every replica counts its own increments.
+type GCounter struct {
+	// Counts holds the increments of every replica, use Inc to change them.
+	Counts map[string]uint64 `json:"counts"`
+}
+
+// Inc adds n to the count of the replica.
+func (c *GCounter) Inc(replica string, n uint64) {
+	if c.Counts == nil {
+		c.Counts = make(map[string]uint64)
+	}
+	c.Counts[replica] += n
+}
+
+// Value returns the sum of the counts of all replicas.
+func (c GCounter) Value() uint64 {
+	var sum uint64
+	for _, n := range c.Counts {
+		sum += n
+	}
+	return sum
+}
+
+// State returns the counts encoded as JSON.
+func (c *GCounter) State() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// Merge keeps the highest count of every replica.
+func (c *GCounter) Merge(state []byte) error {
+	var other GCounter
+	err := json.Unmarshal(state, &other)
+	if err != nil {
+		return err
+	}
+	c.merge(other)
+	return nil
+}
+
+func (c *GCounter) merge(other GCounter) {
+	for replica, n := range other.Counts {
+		if c.Counts == nil {
+			c.Counts = make(map[string]uint64)
+		}
+		c.Counts[replica] = max(c.Counts[replica], n)
+	}
+}
+
+// PNCounter is a counter that can be incremented and decremented.
+type PNCounter struct {
+	// P and N count the increments and decrements of every replica, use Add to change them.
+	P GCounter `json:"p"`
+	N GCounter `json:"n"`
+}
+
+// Add adds n to the counter for the replica, n can be negative.
+func (c *PNCounter) Add(replica string, n int64) {
+	if n < 0 {
+		c.N.Inc(replica, uint64(-n))
+		return
+	}
+	c.P.Inc(replica, uint64(n))
+}
+
+// Value returns the increments minus the decrements of all replicas.
+func (c PNCounter) Value() int64 {
+	return int64(c.P.Value() - c.N.Value())
+}
+
+// State returns the counts encoded as JSON.
+func (c *PNCounter) State() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// Merge keeps the highest increments and decrements of every replica.
+func (c *PNCounter) Merge(state []byte) error {
+	var other PNCounter
+	err := json.Unmarshal(state, &other)
+	if err != nil {
+		return err
+	}
+	c.P.merge(other.P)
+	c.N.merge(other.N)
+	return nil
+}
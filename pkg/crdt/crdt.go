@@ -0,0 +1,40 @@
+// Package crdt provides field types that are merged instead of overwritten when synchronized, so
+// concurrent changes made by several endpoints all survive.
+//
+// Every change is made for a replica, usually the ID of the endpoint making it (see
+// endpoint.Endpoint.ID). The extractor sends the whole state of a changed value and the injector
+// merges it into the local value.
+package crdt
+
+import (
+	"reflect"
+)
+
+// Mergeable is implemented by the pointer to every type of this package. Other types implementing
+// it are synchronized the same way.
+type Mergeable interface {
+	// State returns the encoded state of the value, equal values must have the same state.
+	State() ([]byte, error)
+	// Merge merges an encoded state into the value, merging is commutative, associative and
+	// idempotent.
+	Merge(state []byte) error
+}
+
+var mergeableType = reflect.TypeFor[Mergeable]()
+
+// Is reports if values of the type are merged, the type itself is not a pointer but a pointer to
+// it implements Mergeable.
+func Is(typ reflect.Type) bool {
+	return typ.Kind() != reflect.Pointer && reflect.PointerTo(typ).Implements(mergeableType)
+}
+
+// Of returns the value as a Mergeable, v must be of a type reported by Is. When v is not
+// addressable a copy is returned.
+func Of(v reflect.Value) Mergeable {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface().(Mergeable)
+	}
+	return v.Addr().Interface().(Mergeable)
+}
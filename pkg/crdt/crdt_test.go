@@ -0,0 +1,104 @@
+package crdt
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// merge merges the state of from into to.
+func merge(t *testing.T, to, from Mergeable) {
+	t.Helper()
+	state, err := from.State()
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	if err = to.Merge(state); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+}
+
+func TestGCounter(t *testing.T) {
+	var a, b GCounter
+	a.Inc("a", 2)
+	b.Inc("b", 3)
+	merge(t, &a, &b)
+	merge(t, &b, &a)
+	// merging again must not count twice
+	merge(t, &a, &b)
+	if a.Value() != 5 || b.Value() != 5 {
+		t.Fatalf("a = %d, b = %d, want 5", a.Value(), b.Value())
+	}
+}
+
+func TestPNCounter(t *testing.T) {
+	var a, b PNCounter
+	a.Add("a", 5)
+	b.Add("b", -2)
+	a.Add("a", -1)
+	merge(t, &a, &b)
+	merge(t, &b, &a)
+	if a.Value() != 2 || b.Value() != 2 {
+		t.Fatalf("a = %d, b = %d, want 2", a.Value(), b.Value())
+	}
+}
+
+func TestLWWRegister(t *testing.T) {
+	var a, b LWWRegister[string]
+	a.Set("a", "first")
+	b.Set("b", "second")
+	b.Time = a.Time + 1
+	merge(t, &a, &b)
+	merge(t, &b, &a)
+	if a.Get() != "second" || b.Get() != "second" {
+		t.Fatalf("a = %q, b = %q, want second", a.Get(), b.Get())
+	}
+
+	// the same time is ordered by replica
+	a.Set("a", "a")
+	b.Current, b.Time, b.Replica = "b", a.Time, "b"
+	merge(t, &a, &b)
+	if a.Get() != "b" {
+		t.Fatalf("a = %q, want b", a.Get())
+	}
+}
+
+func TestORSet(t *testing.T) {
+	var a, b ORSet[string]
+	a.Add("a", "x")
+	a.Add("a", "y")
+	merge(t, &b, &a)
+
+	// b removes x while a adds it again, the unseen add wins
+	b.Remove("x")
+	a.Add("a", "x")
+	b.Add("b", "z")
+	a.Remove("y")
+	merge(t, &a, &b)
+	merge(t, &b, &a)
+
+	for _, s := range []*ORSet[string]{&a, &b} {
+		got := s.Values()
+		slices.Sort(got)
+		if !slices.Equal(got, []string{"x", "z"}) || !s.Contains("x") || s.Contains("y") || s.Len() != 2 {
+			t.Fatalf("values = %v, want [x z]", got)
+		}
+	}
+}
+
+func TestIs(t *testing.T) {
+	tests := map[reflect.Type]bool{
+		reflect.TypeFor[GCounter]():              true,
+		reflect.TypeFor[PNCounter]():             true,
+		reflect.TypeFor[LWWRegister[int]]():      true,
+		reflect.TypeFor[ORSet[string]]():         true,
+		reflect.TypeFor[*GCounter]():             false,
+		reflect.TypeFor[map[string]uint64]():     false,
+		reflect.TypeFor[struct{ Counter int }](): false,
+	}
+	for typ, want := range tests {
+		if got := Is(typ); got != want {
+			t.Errorf("Is(%s) = %t, want %t", typ, got, want)
+		}
+	}
+}
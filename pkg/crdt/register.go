@@ -0,0 +1,48 @@
+package crdt
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// LWWRegister holds a single value, the value set last wins. Values set at the same time are
+// ordered by replica so every endpoint keeps the same value.
+type LWWRegister[T any] struct {
+	// Current is the value of the register, use Set to change it.
+	Current T `json:"current"`
+	// Time is when Current was set in nanoseconds since the Unix epoch.
+	Time int64 `json:"time"`
+	// Replica is the replica that set Current.
+	Replica string `json:"replica"`
+}
+
+// Get returns the value of the register.
+func (r LWWRegister[T]) Get() T {
+	return r.Current
+}
+
+// Set sets the value of the register for the replica. The time of the register always moves
+// forward, even when the clock of the replica is behind the replica that set the last value.
+func (r *LWWRegister[T]) Set(replica string, v T) {
+	r.Current = v
+	r.Time = max(time.Now().UnixNano(), r.Time+1)
+	r.Replica = replica
+}
+
+// State returns the register encoded as JSON.
+func (r *LWWRegister[T]) State() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Merge keeps the value set last.
+func (r *LWWRegister[T]) Merge(state []byte) error {
+	var other LWWRegister[T]
+	err := json.Unmarshal(state, &other)
+	if err != nil {
+		return err
+	}
+	if other.Time > r.Time || other.Time == r.Time && other.Replica > r.Replica {
+		*r = other
+	}
+	return nil
+}
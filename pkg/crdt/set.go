@@ -0,0 +1,108 @@
+package crdt
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// ORSet is an observed-remove set, an element added by one replica while another removes it stays
+// in the set. Every Add tags the element, Remove only removes the tags it has seen.
+type ORSet[T comparable] struct {
+	// Tags holds the elements by the tags they were added with, use Add and Remove to change it.
+	Tags map[string]T `json:"tags"`
+	// Removed holds the tags of removed elements.
+	Removed map[string]bool `json:"removed"`
+	// Seq holds the last tag number of every replica.
+	Seq map[string]uint64 `json:"seq"`
+}
+
+// Add adds v to the set for the replica.
+func (s *ORSet[T]) Add(replica string, v T) {
+	if s.Tags == nil {
+		s.Tags = make(map[string]T)
+	}
+	if s.Seq == nil {
+		s.Seq = make(map[string]uint64)
+	}
+	s.Seq[replica]++
+	s.Tags[replica+"/"+strconv.FormatUint(s.Seq[replica], 10)] = v
+}
+
+// Remove removes v from the set.
+func (s *ORSet[T]) Remove(v T) {
+	for tag, e := range s.Tags {
+		if e != v {
+			continue
+		}
+		if s.Removed == nil {
+			s.Removed = make(map[string]bool)
+		}
+		s.Removed[tag] = true
+		delete(s.Tags, tag)
+	}
+}
+
+// Contains reports if v is in the set.
+func (s ORSet[T]) Contains(v T) bool {
+	for _, e := range s.Tags {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns the elements of the set in no particular order.
+func (s ORSet[T]) Values() []T {
+	seen := make(map[T]bool, len(s.Tags))
+	values := make([]T, 0, len(s.Tags))
+	for _, e := range s.Tags {
+		if !seen[e] {
+			seen[e] = true
+			values = append(values, e)
+		}
+	}
+	return values
+}
+
+// Len returns the number of elements in the set.
+func (s ORSet[T]) Len() int {
+	return len(s.Values())
+}
+
+// State returns the set encoded as JSON.
+func (s *ORSet[T]) State() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Merge adds the tags of both sets and removes the tags removed by either.
+func (s *ORSet[T]) Merge(state []byte) error {
+	var other ORSet[T]
+	err := json.Unmarshal(state, &other)
+	if err != nil {
+		return err
+	}
+	for replica, seq := range other.Seq {
+		if s.Seq == nil {
+			s.Seq = make(map[string]uint64)
+		}
+		s.Seq[replica] = max(s.Seq[replica], seq)
+	}
+	for tag := range other.Removed {
+		if s.Removed == nil {
+			s.Removed = make(map[string]bool)
+		}
+		s.Removed[tag] = true
+		delete(s.Tags, tag)
+	}
+	for tag, v := range other.Tags {
+		if s.Removed[tag] {
+			continue
+		}
+		if s.Tags == nil {
+			s.Tags = make(map[string]T)
+		}
+		s.Tags[tag] = v
+	}
+	return nil
+}
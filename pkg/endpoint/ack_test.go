@@ -0,0 +1,248 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// openStream opens a PushPull stream resuming after seq of the session of the server.
+func openStream(ctx context.Context, t *testing.T, client control.ControlClient, session string, seq uint64) control.Control_PushPullClient {
+	t.Helper()
+	stream, err := client.PushPull(ctx)
+	if err != nil {
+		t.Fatalf("PushPull() error: %v", err)
+	}
+	err = stream.Send(&control.Frame{Session: session, Resume: seq})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	return stream
+}
+
+// recvFrame receives the next frame with entries from the stream.
+func recvFrame(t *testing.T, stream control.Control_PushPullClient) *control.Frame {
+	t.Helper()
+	for {
+		f, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+		if f.GetSeq() > 0 {
+			return f
+		}
+	}
+}
+
+// recvAck receives the next frame with entries from the stream and acknowledges it.
+func recvAck(t *testing.T, stream control.Control_PushPullClient) *control.Frame {
+	t.Helper()
+	f := recvFrame(t, stream)
+	err := stream.Send(&control.Frame{Ack: f.GetSeq()})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	return f
+}
+
+// TestPushPull_Acknowledgements verifies frames the client did not acknowledge are sent again on
+// the next stream and acknowledged entries are not.
+func TestPushPull_Acknowledgements(t *testing.T) {
+	port := findFreePort(t)
+	data := &syncStruct{String: "one", Int: 1}
+	ep, err := New(data, &settings.Settings{Port: port, PollInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ep.Run(false)
+	defer ep.Stop()
+	waitForServer(t, ep)
+
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	defer conn.Close()
+	client := control.NewControlClient(conn)
+
+	// the first stream is dropped without acknowledging the frame
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	stream := openStream(ctx, t, client, "", 0)
+	first := recvFrame(t, stream)
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream = openStream(ctx, t, client, "", 0)
+	again := recvFrame(t, stream)
+	if len(again.GetChanges().GetEntries()) != len(first.GetChanges().GetEntries()) || len(again.GetChanges().GetEntries()) != 2 {
+		t.Fatalf("unacknowledged entries not sent again: %v, first %v", again.GetChanges().GetEntries(), first.GetChanges().GetEntries())
+	}
+	err = stream.Send(&control.Frame{Ack: again.GetSeq()})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+
+	ep.Update(func() { data.String = "two" })
+	next := recvFrame(t, stream)
+	if next.GetSeq() != again.GetSeq()+1 || len(next.GetChanges().GetEntries()) != 1 || next.GetChanges().GetEntries()[0].Path() != "String" {
+		t.Fatalf("expected only the new change in frame %d, got frame %d with %v", again.GetSeq()+1, next.GetSeq(), next.GetChanges().GetEntries())
+	}
+}
+
+// TestPushPull_Resume verifies a client resuming its session only receives the change sets it
+// missed and a snapshot once the server no longer holds them.
+func TestPushPull_Resume(t *testing.T) {
+	port := findFreePort(t)
+	data := &syncStruct{String: "one", Int: 1}
+	ep, err := New(data, &settings.Settings{Port: port, PollInterval: -1, HistorySize: 2})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ep.Run(false)
+	defer ep.Stop()
+	waitForServer(t, ep)
+
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	defer conn.Close()
+	client := control.NewControlClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	streamCtx, streamCancel := context.WithCancel(ctx)
+	snapshot := recvAck(t, openStream(streamCtx, t, client, "", 0))
+	streamCancel()
+	if !snapshot.GetSnapshot() || snapshot.GetSession() == "" || len(snapshot.GetChanges().GetEntries()) != 2 {
+		t.Fatalf("expected a snapshot of the data, got %v", snapshot)
+	}
+
+	ep.Update(func() { data.String = "two" })
+	streamCtx, streamCancel = context.WithCancel(ctx)
+	observer := openStream(streamCtx, t, client, snapshot.GetSession(), snapshot.GetSeq())
+	next := recvAck(t, observer)
+	if next.GetSnapshot() || next.GetSeq() != snapshot.GetSeq()+1 || len(next.GetChanges().GetEntries()) != 1 {
+		t.Fatalf("expected only the missed change set %d, got %v", snapshot.GetSeq()+1, next)
+	}
+
+	// the observer waits for every change set so each is recorded on its own
+	for i := 3; i <= 5; i++ {
+		ep.Update(func() { data.Int = i })
+		recvAck(t, observer)
+	}
+	streamCancel()
+
+	f := recvAck(t, openStream(ctx, t, client, snapshot.GetSession(), next.GetSeq()))
+	if !f.GetSnapshot() || f.GetSeq() != next.GetSeq()+3 {
+		t.Fatalf("expected a snapshot at %d, got %v", next.GetSeq()+3, f)
+	}
+	for _, e := range f.GetChanges().GetEntries() {
+		if e.Path() == "Int" && e.GetValue().GetInt64() != 5 {
+			t.Fatalf("snapshot holds Int %v, want 5", e.GetValue())
+		}
+	}
+}
+
+// TestPushPull_SnapshotRemovesStale verifies a client receiving a snapshot removes what the server
+// no longer has instead of sending it back.
+func TestPushPull_SnapshotRemovesStale(t *testing.T) {
+	port := findFreePort(t)
+	first, err := New(&syncStruct{Map: map[string]int{"kept": 1, "stale": 2}}, &settings.Settings{Port: port, AutoUpdate: true})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	first.Run(false)
+	waitForServer(t, first)
+
+	data := &syncStruct{}
+	client, err := New(data, &settings.Settings{
+		Peers:      []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate: true,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	client.Run(true)
+	defer client.Stop()
+	waitForLocked(t, client, func() bool { return data.Map["stale"] == 2 })
+	first.Stop()
+
+	// a server with a new session and without the stale key takes over
+	serverData := &syncStruct{Map: map[string]int{"kept": 1}}
+	second, err := New(serverData, &settings.Settings{Port: port, AutoUpdate: true})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	second.Run(false)
+	defer second.Stop()
+	waitForServer(t, second)
+
+	waitForLocked(t, client, func() bool {
+		_, ok := data.Map["stale"]
+		return !ok && data.Map["kept"] == 1
+	})
+	time.Sleep(300 * time.Millisecond)
+	second.RLock()
+	_, pushed := serverData.Map["stale"]
+	second.RUnlock()
+	if pushed {
+		t.Fatal("stale key sent back to the server")
+	}
+}
+
+// TestPushPull_RunAgain verifies a client run again after stopping keeps exchanging changes with
+// the server and stops again.
+func TestPushPull_RunAgain(t *testing.T) {
+	port := findFreePort(t)
+	serverData := &syncStruct{String: "server"}
+	server, err := New(serverData, &settings.Settings{Port: port, AutoUpdate: true})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	server.Run(false)
+	defer server.Stop()
+	waitForServer(t, server)
+
+	data := &syncStruct{}
+	client, err := New(data, &settings.Settings{
+		Peers:      []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate: true,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	client.Run(true)
+	waitForLocked(t, client, func() bool { return data.String == "server" })
+	client.Stop()
+
+	client.Run(true)
+	waitForRunning2(t, client)
+	// every change set of the server is applied, the second one used to block without the debounce
+	// of the first run
+	for i := 1; i <= 3; i++ {
+		server.Update(func() { serverData.Int = i })
+		waitForLocked(t, client, func() bool { return data.Int == i })
+	}
+	client.Update(func() { data.String = "client" })
+	waitForLocked(t, server, func() bool { return serverData.String == "client" })
+
+	stopped := make(chan struct{})
+	go func() {
+		client.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop() did not return")
+	}
+}
@@ -0,0 +1,83 @@
+package endpoint
+
+import (
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/acl"
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// TestACL_RejectedWritesReported verifies the server refuses client writes to protected paths,
+// keeps applying allowed writes on the same stream and reports the rejection to the client, which
+// sets the protected path back to the value of the server.
+func TestACL_RejectedWritesReported(t *testing.T) {
+	port := findFreePort(t)
+	rules, err := acl.New(acl.Rule{Path: "Sub.*", Allow: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverData := &syncStruct{Sub: subStruct{Name: "server owned"}}
+	serverEP, err := New(serverData, &settings.Settings{
+		Port:       port,
+		AutoUpdate: true,
+		ACL:        rules,
+	})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.SetLogger(slog.NewTextHandler(&lockedBuffer{}, nil))
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+	time.Sleep(500 * time.Millisecond)
+
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:       port + 1,
+		Peers:      []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate: true,
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	clientLogs := &lockedBuffer{}
+	clientEP.SetLogger(slog.NewTextHandler(clientLogs, nil))
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP)
+	time.Sleep(time.Second)
+
+	clientEP.With(func() {
+		clientData.Sub.Name = "client write"
+		clientData.String = "allowed"
+	})
+
+	applied := func() bool {
+		serverEP.RLock()
+		defer serverEP.RUnlock()
+		return serverData.String == "allowed"
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !applied() || !strings.Contains(clientLogs.String(), "server rejected write") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected allowed write applied and rejection reported, logs %s", clientLogs.String())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !strings.Contains(clientLogs.String(), "Sub.Name") {
+		t.Fatalf("rejection does not name the path: %s", clientLogs.String())
+	}
+	waitForLocked(t, clientEP, func() bool { return clientData.Sub.Name == "server owned" })
+	// the value set back is not sent to the server again
+	time.Sleep(300 * time.Millisecond)
+	serverEP.RLock()
+	defer serverEP.RUnlock()
+	if serverData.Sub.Name != "server owned" {
+		t.Fatalf("protected field overwritten: %q", serverData.Sub.Name)
+	}
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+var (
+	ErrUnauthenticated = errors.New("peer could not be authenticated")
+	ErrMissingMetadata = errors.New("authentication metadata missing")
+)
+
+const (
+	// identityKey is the metadata key a client sends its name in.
+	identityKey = "syncer-identity"
+	// tokenKey is the metadata key a shared token is sent in.
+	tokenKey = "syncer-token"
+	// timestampKey is the metadata key the signing time of an HMAC is sent in.
+	timestampKey = "syncer-timestamp"
+	// nonceKey is the metadata key the nonce of an HMAC is sent in.
+	nonceKey = "syncer-nonce"
+	// signatureKey is the metadata key the HMAC signature is sent in.
+	signatureKey = "syncer-signature"
+)
+
+// Action is an operation a peer asks the server to perform.
+type Action int
+
+const (
+	Ping Action = iota
+	Shutdown
+	Pull
+	Push
+	PushPull
+	Elect
+)
+
+func (a Action) String() string {
+	switch a {
+	case Ping:
+		return "ping"
+	case Shutdown:
+		return "shutdown"
+	case Pull:
+		return "pull"
+	case Push:
+		return "push"
+	case PushPull:
+		return "pushpull"
+	case Elect:
+		return "elect"
+	default:
+		return "unknown"
+	}
+}
+
+// Authenticator verifies the peer making a request and returns its identity.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (string, error)
+}
+
+// AuthenticatorFunc allows a plain function to be used as an Authenticator.
+type AuthenticatorFunc func(ctx context.Context) (string, error)
+
+// Authenticate calls f(ctx).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Authorizer decides if the peer with the given identity may perform the action.
+type Authorizer func(identity string, action Action) bool
+
+type identityCtxKey struct{}
+
+// NewContext returns a copy of ctx carrying the identity of the peer.
+func NewContext(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityCtxKey{}, identity)
+}
+
+// FromContext returns the identity stored in ctx by the server.
+func FromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(identityCtxKey{}).(string)
+	return identity, ok
+}
+
+// PeerIdentity returns the identity of the peer derived from the connection, the common name
+// of a verified client certificate or the host of the remote address. The port is left out, it
+// changes with every connection of the peer.
+func PeerIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		if cert := verifiedLeaf(info.State.VerifiedChains); cert != nil && cert.Subject.CommonName != "" {
+			return cert.Subject.CommonName
+		}
+	}
+	if p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func verifiedLeaf(chains [][]*x509.Certificate) *x509.Certificate {
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+	return chains[0][0]
+}
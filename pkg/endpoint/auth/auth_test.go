@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const pushPull = "/control.Control/PushPull"
+
+type perRPC interface {
+	GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error)
+}
+
+// incoming turns the metadata a client would send calling PushPull into a server side context.
+func incoming(t *testing.T, creds perRPC) context.Context {
+	t.Helper()
+	return call(t, creds, pushPull, pushPull)
+}
+
+// call turns the metadata a client would send calling sent into a server side context of a call
+// to method.
+func call(t *testing.T, creds perRPC, sent, method string) context.Context {
+	t.Helper()
+	ctx := credentials.NewContextWithRequestInfo(context.Background(), credentials.RequestInfo{Method: sent})
+	md, err := creds.GetRequestMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = grpc.NewContextWithServerTransportStream(context.Background(), methodStream(method))
+	return metadata.NewIncomingContext(ctx, metadata.New(md))
+}
+
+// methodStream is the transport stream of a call to the method.
+type methodStream string
+
+func (m methodStream) Method() string               { return string(m) }
+func (m methodStream) SetHeader(metadata.MD) error  { return nil }
+func (m methodStream) SendHeader(metadata.MD) error { return nil }
+func (m methodStream) SetTrailer(metadata.MD) error { return nil }
+
+func TestSharedToken_Authenticate(t *testing.T) {
+	server := &SharedToken{Token: "secret", Tokens: map[string]string{"alpha": "alpha-secret"}}
+
+	tests := []struct {
+		name     string
+		client   *SharedToken
+		identity string
+		wantErr  error
+	}{
+		{
+			name:     "own token",
+			client:   &SharedToken{Token: "alpha-secret", Name: "alpha"},
+			identity: "alpha",
+		},
+		{
+			name:   "shared token does not bind the name",
+			client: &SharedToken{Token: "secret", Name: "alpha"},
+		},
+		{
+			name:    "token of another identity",
+			client:  &SharedToken{Token: "alpha-secret", Name: "beta"},
+			wantErr: ErrUnauthenticated,
+		},
+		{
+			name:    "wrong token",
+			client:  &SharedToken{Token: "wrong", Name: "alpha"},
+			wantErr: ErrUnauthenticated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := server.Authenticate(incoming(t, tt.client))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if identity != tt.identity {
+				t.Fatalf("identity = %q, want %q", identity, tt.identity)
+			}
+		})
+	}
+
+	_, err := server.Authenticate(context.Background())
+	if !errors.Is(err, ErrMissingMetadata) {
+		t.Fatalf("expected ErrMissingMetadata, got %v", err)
+	}
+}
+
+func TestHMAC_Authenticate(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+	server := &HMAC{Key: []byte("key"), Keys: map[string][]byte{"alpha": []byte("alpha-key")}, now: clock}
+
+	tests := []struct {
+		name     string
+		client   *HMAC
+		identity string
+		wantErr  error
+	}{
+		{
+			name:     "own key",
+			client:   &HMAC{Key: []byte("alpha-key"), Name: "alpha", now: clock},
+			identity: "alpha",
+		},
+		{
+			name:   "shared key does not bind the name",
+			client: &HMAC{Key: []byte("key"), Name: "alpha", now: clock},
+		},
+		{
+			name:    "key of another identity",
+			client:  &HMAC{Key: []byte("alpha-key"), Name: "beta", now: clock},
+			wantErr: ErrUnauthenticated,
+		},
+		{
+			name:    "wrong key",
+			client:  &HMAC{Key: []byte("other"), Name: "alpha", now: clock},
+			wantErr: ErrUnauthenticated,
+		},
+		{
+			name:    "expired",
+			client:  &HMAC{Key: []byte("alpha-key"), Name: "alpha", now: func() time.Time { return now.Add(-2 * time.Minute) }},
+			wantErr: ErrUnauthenticated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := server.Authenticate(incoming(t, tt.client))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if identity != tt.identity {
+				t.Fatalf("identity = %q, want %q", identity, tt.identity)
+			}
+		})
+	}
+}
+
+// TestHMAC_Replay verifies a signature is refused when it is sent again or for another method.
+func TestHMAC_Replay(t *testing.T) {
+	server := &HMAC{Keys: map[string][]byte{"alpha": []byte("alpha-key")}}
+	client := &HMAC{Key: []byte("alpha-key"), Name: "alpha"}
+
+	ctx := incoming(t, client)
+	if _, err := server.Authenticate(ctx); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if _, err := server.Authenticate(ctx); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("replayed Authenticate() error = %v, want %v", err, ErrUnauthenticated)
+	}
+	_, err := server.Authenticate(call(t, client, "/control.Control/Pull", "/control.Control/Control"))
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("Authenticate() of another method error = %v, want %v", err, ErrUnauthenticated)
+	}
+	if _, err = server.Authenticate(incoming(t, client)); err != nil {
+		t.Fatalf("Authenticate() with a new nonce error = %v", err)
+	}
+}
+
+// TestRequireTransportSecurity verifies credentials are only sent over TLS unless allowed.
+func TestRequireTransportSecurity(t *testing.T) {
+	for _, creds := range []credentials.PerRPCCredentials{&HMAC{}, &SharedToken{}} {
+		if !creds.RequireTransportSecurity() {
+			t.Fatalf("%T sent over a plaintext connection by default", creds)
+		}
+	}
+	for _, creds := range []credentials.PerRPCCredentials{&HMAC{AllowInsecure: true}, &SharedToken{AllowInsecure: true}} {
+		if creds.RequireTransportSecurity() {
+			t.Fatalf("%T not sent over a plaintext connection when allowed", creds)
+		}
+	}
+}
+
+// TestPeerIdentity verifies a peer without a certificate is identified by its host, whatever port
+// it connects from.
+func TestPeerIdentity(t *testing.T) {
+	for _, port := range []int{50001, 50002} {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: port}})
+		if identity := PeerIdentity(ctx); identity != "10.0.0.1" {
+			t.Fatalf("PeerIdentity() = %q, want 10.0.0.1", identity)
+		}
+	}
+	if identity := PeerIdentity(context.Background()); identity != "" {
+		t.Fatalf("PeerIdentity() without a peer = %q, want empty", identity)
+	}
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const defaultMaxSkew = time.Minute
+
+// HMAC authenticates peers by signing their name, the current time, a nonce and the method called
+// with a key, the key itself never crosses the network. Signatures older than MaxSkew and nonces
+// seen before are refused, so a signature cannot be replayed. The server only trusts the name when
+// it is signed with the key of that name in Keys, a peer signing with the shared Key is identified
+// by PeerIdentity.
+// HMAC is both an Authenticator for the server and credentials for the client.
+type HMAC struct {
+	Key  []byte
+	Name string
+	// Keys holds the key of each identity, used by the server.
+	Keys map[string][]byte
+	// MaxSkew is the maximum age of a signature, defaults to one minute.
+	MaxSkew time.Duration
+	// AllowInsecure sends the signature over a plaintext connection, by default it is only sent
+	// over TLS.
+	AllowInsecure bool
+
+	now func() time.Time
+
+	// nonces holds the time of the nonces seen by the server until their signature expires
+	noncesMu sync.Mutex
+	nonces   map[string]time.Time
+}
+
+// Authenticate verifies the signature sent by the peer and returns the signed name.
+func (h *HMAC) Authenticate(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", ErrMissingMetadata
+	}
+	name, ts, nonce, sig := first(md, identityKey), first(md, timestampKey), first(md, nonceKey), first(md, signatureKey)
+	if ts == "" || nonce == "" || sig == "" {
+		return "", fmt.Errorf("%w: %s, %s, %s", ErrMissingMetadata, timestampKey, nonceKey, signatureKey)
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid timestamp", ErrUnauthenticated)
+	}
+	signed := time.Unix(unix, 0)
+	if age := h.clock().Sub(signed).Abs(); age > h.maxSkew() {
+		return "", fmt.Errorf("%w: signature expired", ErrUnauthenticated)
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid signature", ErrUnauthenticated)
+	}
+	// the method is the one called on the server, a signature made for another method fails
+	method, _ := grpc.Method(ctx)
+	identity := ""
+	if key, ok := h.Keys[name]; ok && name != "" && hmac.Equal(got, sign(key, name, ts, nonce, method)) {
+		identity = name
+	} else if len(h.Key) == 0 || !hmac.Equal(got, sign(h.Key, name, ts, nonce, method)) {
+		return "", fmt.Errorf("%w: invalid signature", ErrUnauthenticated)
+	}
+	if !h.fresh(nonce, signed) {
+		return "", fmt.Errorf("%w: signature replayed", ErrUnauthenticated)
+	}
+	if identity == "" {
+		return PeerIdentity(ctx), nil
+	}
+	return identity, nil
+}
+
+// fresh records the nonce of a signature made at signed and returns false when it was seen before.
+// Nonces are forgotten once their signature expired.
+func (h *HMAC) fresh(nonce string, signed time.Time) bool {
+	h.noncesMu.Lock()
+	defer h.noncesMu.Unlock()
+	now := h.clock()
+	for n, t := range h.nonces {
+		if now.Sub(t).Abs() > h.maxSkew() {
+			delete(h.nonces, n)
+		}
+	}
+	if _, ok := h.nonces[nonce]; ok {
+		return false
+	}
+	if h.nonces == nil {
+		h.nonces = make(map[string]time.Time)
+	}
+	h.nonces[nonce] = signed
+	return true
+}
+
+// GetRequestMetadata signs the name with the current time, a new nonce and the method called for
+// every request made by the client.
+func (h *HMAC) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	ts := strconv.FormatInt(h.clock().Unix(), 10)
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	nonce := hex.EncodeToString(b)
+	var method string
+	if ri, ok := credentials.RequestInfoFromContext(ctx); ok {
+		method = ri.Method
+	}
+	return map[string]string{
+		identityKey:  h.Name,
+		timestampKey: ts,
+		nonceKey:     nonce,
+		signatureKey: hex.EncodeToString(sign(h.Key, h.Name, ts, nonce, method)),
+	}, nil
+}
+
+// RequireTransportSecurity reports if the signature may only be sent over TLS.
+func (h *HMAC) RequireTransportSecurity() bool {
+	return !h.AllowInsecure
+}
+
+func sign(key []byte, name, ts, nonce, method string) []byte {
+	mac := hmac.New(sha256.New, key)
+	for i, s := range []string{name, ts, nonce, method} {
+		if i > 0 {
+			mac.Write([]byte{'\n'})
+		}
+		mac.Write([]byte(s))
+	}
+	return mac.Sum(nil)
+}
+
+func (h *HMAC) clock() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
+
+func (h *HMAC) maxSkew() time.Duration {
+	if h.MaxSkew > 0 {
+		return h.MaxSkew
+	}
+	return defaultMaxSkew
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// SharedToken authenticates peers presenting a known token. On the client Name is sent along with
+// the token. The server only trusts the name when the token matches the one of that name in
+// Tokens, a peer presenting the shared Token is identified by PeerIdentity.
+// SharedToken is both an Authenticator for the server and credentials for the client.
+type SharedToken struct {
+	Token string
+	Name  string
+	// Tokens holds the token of each identity, used by the server.
+	Tokens map[string]string
+	// AllowInsecure sends the token over a plaintext connection, by default it is only sent over
+	// TLS.
+	AllowInsecure bool
+}
+
+// Authenticate checks the token sent by the peer.
+func (s *SharedToken) Authenticate(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", ErrMissingMetadata
+	}
+	token := first(md, tokenKey)
+	if token == "" {
+		return "", fmt.Errorf("%w: %s", ErrMissingMetadata, tokenKey)
+	}
+	if name := first(md, identityKey); name != "" {
+		if want, ok := s.Tokens[name]; ok && equal(token, want) {
+			return name, nil
+		}
+	}
+	if s.Token == "" || !equal(token, s.Token) {
+		return "", fmt.Errorf("%w: invalid token", ErrUnauthenticated)
+	}
+	return PeerIdentity(ctx), nil
+}
+
+// GetRequestMetadata adds the token to every request made by the client.
+func (s *SharedToken) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	md := map[string]string{tokenKey: s.Token}
+	if s.Name != "" {
+		md[identityKey] = s.Name
+	}
+	return md, nil
+}
+
+// RequireTransportSecurity reports if the token may only be sent over TLS.
+func (s *SharedToken) RequireTransportSecurity() bool {
+	return !s.AllowInsecure
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+package endpoint
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/auth"
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+	"google.golang.org/grpc"
+)
+
+// TestAuth_TokenAndAuthorization verifies authenticated peers can sync but are refused actions the
+// authorizer denies, that peers with a wrong token are rejected and that user interceptors see the
+// identity of the peer.
+func TestAuth_TokenAndAuthorization(t *testing.T) {
+	port := findFreePort(t)
+
+	var seen atomic.Value
+	serverEP, err := New(&syncStruct{String: "guarded"}, &settings.Settings{
+		Port:          port,
+		AutoUpdate:    true,
+		Authenticator: &auth.SharedToken{Tokens: map[string]string{"reader": "secret"}},
+		Authorize: func(identity string, action auth.Action) bool {
+			return !(identity == "reader" && action == auth.Shutdown)
+		},
+		UnaryInterceptors: []grpc.UnaryServerInterceptor{
+			func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if identity, ok := auth.FromContext(ctx); ok {
+					seen.Store(identity)
+				}
+				return handler(ctx, req)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.SetLogger(slog.NewTextHandler(&lockedBuffer{}, nil))
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+	time.Sleep(500 * time.Millisecond)
+
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:        port + 1,
+		Peers:       []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate:  true,
+		Credentials: &auth.SharedToken{Token: "secret", Name: "reader", AllowInsecure: true},
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP)
+	time.Sleep(time.Second)
+
+	clientEP.RLock()
+	synced := clientData.String == "guarded"
+	clientEP.RUnlock()
+	if !synced {
+		t.Fatal("authenticated client not synced")
+	}
+	if seen.Load() != "reader" {
+		t.Fatalf("interceptor saw identity %v, want reader", seen.Load())
+	}
+
+	clientEP.client.ShutdownRemoteServer()
+	time.Sleep(200 * time.Millisecond)
+	if !serverEP.IsServer() || !serverEP.server.Running() {
+		t.Fatal("server shut down by peer denied the shutdown action")
+	}
+
+	badData := &syncStruct{}
+	badEP, err := New(badData, &settings.Settings{
+		Port:        port + 2,
+		Peers:       []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		Credentials: &auth.SharedToken{Token: "secret", Name: "intruder", AllowInsecure: true},
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	badLogs := &lockedBuffer{}
+	badEP.SetLogger(slog.NewTextHandler(badLogs, nil))
+	badEP.Run(true)
+	defer badEP.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(badLogs.String(), "client rejected by server") {
+		if time.Now().After(deadline) {
+			t.Fatal("rejected client did not report the rejection")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if badEP.Running() || badData.String != "" {
+		t.Fatal("client with the token of another identity was accepted")
+	}
+}
+
+// TestAuth_HMAC verifies a client signing every call with its key syncs with the server.
+func TestAuth_HMAC(t *testing.T) {
+	port := findFreePort(t)
+	key := []byte("reader-key")
+	serverEP, err := New(&syncStruct{String: "signed"}, &settings.Settings{
+		Port:          port,
+		AutoUpdate:    true,
+		Authenticator: &auth.HMAC{Keys: map[string][]byte{"reader": key}},
+	})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:        port + 1,
+		Peers:       []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate:  true,
+		Credentials: &auth.HMAC{Key: key, Name: "reader", AllowInsecure: true},
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForLocked(t, clientEP, func() bool { return clientData.String == "signed" })
+}
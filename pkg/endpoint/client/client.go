@@ -9,27 +9,37 @@ import (
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kjbreil/syncer/pkg/combined"
 	"github.com/kjbreil/syncer/pkg/control"
 	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+	"github.com/kjbreil/syncer/pkg/injector"
+	"github.com/kjbreil/syncer/pkg/merkle"
 	slogchannel "github.com/samber/slog-channel"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 )
 
 var (
-	ErrClientNotAvailable = fmt.Errorf("could not dial Client")
-	ErrClientInjector     = fmt.Errorf("client could not create injector")
+	ErrClientNotAvailable  = fmt.Errorf("could not dial Client")
+	ErrClientInjector      = fmt.Errorf("client could not create injector")
+	ErrClientTLS           = fmt.Errorf("client could not configure tls")
+	ErrClientHandshake     = fmt.Errorf("client tls handshake failed")
+	ErrClientRejected      = fmt.Errorf("client rejected by server")
+	ErrClientWriteRejected = fmt.Errorf("server rejected write")
 )
 
 type Client struct {
 	c    control.ControlClient
 	conn *grpc.ClientConn
 	peer net.TCPAddr
+	// leader is the server in the leader election
+	leader *control.Leader
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -37,19 +47,27 @@ type Client struct {
 	settings *settings.Settings
 
 	combined *combined.Combined
+	// session outlives the client, the next client resumes from it
+	session *Session
+	// origin is the ID of the endpoint, the server does not send its change sets back
+	origin string
 	// injector *injector.Injector
 	// client extractor not used yet
 	// extractor *extractor.Extractor
 	data any
+	// notify is signalled by Notify to send the changes of the data immediately
+	notify chan struct{}
 
 	logger *slog.Logger
 }
 
 // New creates a new client that connects to the given peer.
 // The given data is used to synchronize the local state with the remote one.
+// The given shared state holds the lock of the data, the subscriptions and the journal.
+// The given session is resumed and kept up to date, nil starts a new one.
 // The given errors channel is used to send log records.
 // The given settings are used to control the behavior of the client.
-func New(ctx context.Context, wg *sync.WaitGroup, data any, peer net.TCPAddr, errs chan *slog.Record, settings *settings.Settings) (*Client, error) {
+func New(ctx context.Context, wg *sync.WaitGroup, data any, shared combined.Shared, session *Session, peer net.TCPAddr, errs chan *slog.Record, settings *settings.Settings) (*Client, error) {
 	var err error
 
 	c := &Client{
@@ -57,21 +75,23 @@ func New(ctx context.Context, wg *sync.WaitGroup, data any, peer net.TCPAddr, er
 		logger:   slog.New(slogchannel.Option{Level: slog.LevelDebug, Channel: errs}.NewChannelHandler()),
 		settings: settings,
 		data:     data,
+		notify:   make(chan struct{}, 1),
+		session:  session,
+	}
+	if c.session == nil {
+		c.session = NewSession()
+	}
+	if shared.Clock != nil {
+		c.origin = shared.Clock.Origin()
 	}
 
 	c.ctx, c.cancel = context.WithCancel(ctx)
 
-	var opts []grpc.DialOption
-	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	opts = append(opts, grpc.WithBlock())
-
-	addr := net.JoinHostPort(peer.IP.String(), strconv.Itoa(peer.Port))
-
-	dialCtx, cancel := context.WithTimeout(c.ctx, time.Second)
-	defer cancel()
-	c.conn, err = grpc.DialContext(dialCtx, addr, opts...)
+	c.conn, err = dial(c.ctx, peer, settings, time.Second, func(err error) {
+		c.logger.Error(fmt.Errorf("%w: %s: %w", ErrClientHandshake, peer.String(), err).Error())
+	})
 	if err != nil {
-		return nil, ErrClientNotAvailable
+		return nil, c.closeWithError(err)
 	}
 
 	c.c = control.NewControlClient(c.conn)
@@ -82,17 +102,34 @@ func New(ctx context.Context, wg *sync.WaitGroup, data any, peer net.TCPAddr, er
 		for {
 			select {
 			case <-time.After(time.Second * 5):
-				_, err = c.c.Control(c.ctx, &control.Message{Action: control.Message_PING})
+				_, err := c.c.Control(c.ctx, &control.Message{Action: control.Message_PING})
 				if err != nil {
 					c.logger.Error(fmt.Errorf("context error: %w", err).Error())
 					c.cancel()
 				}
 			case <-c.ctx.Done():
+				// the client stopped or its stream failed, the connection is not used anymore
+				_ = c.conn.Close()
 				return
 			}
 		}
 	}()
 
+	resp, err := c.c.Control(c.ctx, &control.Message{Action: control.Message_PING})
+	if err != nil {
+		if code := status.Code(err); code == codes.Unauthenticated || code == codes.PermissionDenied {
+			c.logger.Error(fmt.Errorf("%w: %s: %w", ErrClientRejected, peer.String(), err).Error())
+		}
+		return nil, c.closeWithError(fmt.Errorf("%w: %w", ErrClientNotAvailable, err))
+	}
+	c.leader = resp.GetLeader()
+
+	c.combined, err = c.session.open(ctx, data, shared)
+	if err != nil {
+		return nil, c.closeWithError(fmt.Errorf("%w: %w", ErrClientInjector, err))
+	}
+
+	// PushPull uses the combined extractor and injector so it is started once they exist
 	if settings.AutoUpdate {
 		wg.Add(1)
 		go func() {
@@ -101,23 +138,131 @@ func New(ctx context.Context, wg *sync.WaitGroup, data any, peer net.TCPAddr, er
 		}()
 	}
 
-	_, err = c.c.Control(c.ctx, &control.Message{Action: control.Message_PING})
+	return c, nil
+}
+
+// dial connects to the server at peer within timeout, report is called with failed TLS handshakes.
+func dial(ctx context.Context, peer net.TCPAddr, settings *settings.Settings, timeout time.Duration, report func(error)) (*grpc.ClientConn, error) {
+	var opts []grpc.DialOption
+	if settings.TLS != nil {
+		tlsCfg, err := settings.TLS.ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrClientTLS, err)
+		}
+		opts = append(opts, grpc.WithTransportCredentials(&reportingCredentials{
+			TransportCredentials: credentials.NewTLS(tlsCfg),
+			report:               report,
+		}))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if settings.Credentials != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(settings.Credentials))
+	}
+	opts = append(opts, grpc.WithBlock())
+
+	addr := net.JoinHostPort(peer.IP.String(), strconv.Itoa(peer.Port))
+
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, addr, opts...)
 	if err != nil {
-		return nil, c.closeWithError(fmt.Errorf("%w: %w", ErrClientNotAvailable, err))
+		return nil, ErrClientNotAvailable
 	}
+	return conn, nil
+}
+
+// Dial connects to the server at peer without starting a client, e.g. to link to a peer of a mesh.
+// A failed TLS handshake is returned as ErrClientHandshake.
+func Dial(ctx context.Context, peer net.TCPAddr, settings *settings.Settings) (*grpc.ClientConn, error) {
+	return connect(ctx, peer, settings, time.Second)
+}
 
-	c.combined, err = combined.New(c.ctx, data)
+// connect dials the server at peer within timeout and returns a failed TLS handshake as
+// ErrClientHandshake.
+func connect(ctx context.Context, peer net.TCPAddr, settings *settings.Settings, timeout time.Duration) (*grpc.ClientConn, error) {
+	handshake := make(chan error, 1)
+	conn, err := dial(ctx, peer, settings, timeout, func(err error) {
+		select {
+		case handshake <- err:
+		default:
+		}
+	})
 	if err != nil {
-		return nil, c.closeWithError(fmt.Errorf("%w: %w", ErrClientInjector, err))
+		select {
+		case herr := <-handshake:
+			return nil, fmt.Errorf("%w: %s: %w", ErrClientHandshake, peer.String(), herr)
+		default:
+			return nil, err
+		}
 	}
+	return conn, nil
+}
 
-	return c, nil
+// Probe returns the server at peer in the leader election without syncing with it, nil when the
+// server takes no part in the election.
+func Probe(ctx context.Context, peer net.TCPAddr, settings *settings.Settings) (*control.Leader, error) {
+	resp, err := sendControl(ctx, peer, settings, &control.Message{Action: control.Message_PING})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetLeader(), nil
+}
+
+// Elect asks the server at peer to step down for the candidate. It returns false and the server
+// when the server refuses.
+func Elect(ctx context.Context, peer net.TCPAddr, settings *settings.Settings, candidate *control.Leader) (bool, *control.Leader, error) {
+	resp, err := sendControl(ctx, peer, settings, &control.Message{Action: control.Message_ELECT, Candidate: candidate})
+	if err != nil {
+		return false, nil, err
+	}
+	return resp.GetType() == control.Response_OK, resp.GetLeader(), nil
+}
+
+// sendControl sends a single control message to the server at peer, an unreachable peer is given
+// up on within the election interval. Failed TLS handshakes and rejections by the server are
+// returned as ErrClientHandshake and ErrClientRejected.
+func sendControl(ctx context.Context, peer net.TCPAddr, settings *settings.Settings, msg *control.Message) (*control.Response, error) {
+	conn, err := connect(ctx, peer, settings, min(time.Second, settings.Election()))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	resp, err := control.NewControlClient(conn).Control(ctx, msg)
+	if err != nil {
+		if code := status.Code(err); code == codes.Unauthenticated || code == codes.PermissionDenied {
+			return nil, fmt.Errorf("%w: %s: %w", ErrClientRejected, peer.String(), err)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrClientNotAvailable, err)
+	}
+	return resp, nil
+}
+
+// Leader returns the server the client is connected to in the leader election, nil when the
+// server takes no part in it.
+func (c *Client) Leader() *control.Leader {
+	return c.leader
+}
+
+// Peer returns the address of the server the client is connected to.
+func (c *Client) Peer() net.TCPAddr {
+	return c.peer
 }
 
 func (c *Client) Running() bool {
 	return c.ctx.Err() == nil
 }
 
+// Done returns a channel closed once the client is disconnected from the server.
+func (c *Client) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+// Stop disconnects the client from the server.
+func (c *Client) Stop() {
+	c.cancel()
+}
+
 func (c *Client) AddExtHandler(ext func() error) {
 	c.combined.ExtractorChanges(ext)
 }
@@ -126,6 +271,15 @@ func (c *Client) AddInjHandler(inj func() error) {
 	c.combined.InjectorChanges(inj)
 }
 
+// Notify signals PushPull to extract and send the changes of the data immediately instead of
+// waiting for the next poll.
+func (c *Client) Notify() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
 // Init requests to init data from the server.
 func (c *Client) Init() {
 	update, err := c.c.Pull(c.ctx, &control.Request{Type: control.Request_INIT})
@@ -151,9 +305,27 @@ func (c *Client) PushPull() {
 		c.logger.Error(fmt.Errorf("Client.PushPull(): %w", err).Error())
 		return
 	}
+	// the first frame tells the server the change sets to continue with
+	id, resume := c.session.position()
+	err = client.Send(&control.Frame{Session: id, Resume: resume, Origin: c.origin})
+	if err != nil {
+		c.logger.Error(fmt.Errorf("Client.PushPull(): %w", err).Error())
+		return
+	}
 	var wg sync.WaitGroup
 	mu := &sync.Mutex{}
-	checkInterval := time.Millisecond * 1000
+
+	// sent is the last frame sent, acked the last frame acknowledged by the server, acks is
+	// signalled when it changes
+	var sent, acked atomic.Uint64
+	acks := make(chan struct{}, 1)
+
+	var poll <-chan time.Time
+	if interval, ok := c.settings.Polling(); ok {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
 
 	wg.Add(1)
 	go func() {
@@ -161,25 +333,39 @@ func (c *Client) PushPull() {
 		defer c.cancel()
 		for {
 			select {
-			case <-time.After(checkInterval):
-				mu.Lock()
-				entries, err := c.combined.Entries(c.data)
-				if err != nil {
-					c.logger.Error(err.Error())
-					mu.Unlock()
-				}
-				for _, e := range entries {
-					err := client.Send(e)
-					if err != nil {
-						c.logger.Error(err.Error())
-						mu.Unlock()
-						return
-					}
-				}
-				mu.Unlock()
+			case <-poll:
+			case <-c.notify:
 			case <-c.ctx.Done():
 				return
 			}
+			mu.Lock()
+			entries, err := c.combined.Pending(c.data)
+			if err != nil {
+				c.logger.Error(err.Error())
+			}
+			if len(entries) == 0 {
+				mu.Unlock()
+				continue
+			}
+			seq := sent.Add(1)
+			err = client.Send(&control.Frame{Seq: seq, Changes: &control.ChangeSet{Entries: entries, Origin: c.origin}})
+			mu.Unlock()
+			if err != nil {
+				c.logger.Error(err.Error())
+				return
+			}
+			// the entries are sent again on the next connection until the server applied them
+			for acked.Load() < seq {
+				select {
+				case <-acks:
+				case <-c.ctx.Done():
+					return
+				}
+			}
+			err = c.combined.Acknowledge(entries)
+			if err != nil {
+				c.logger.Error(err.Error())
+			}
 		}
 	}()
 
@@ -188,7 +374,7 @@ func (c *Client) PushPull() {
 		defer wg.Done()
 		defer c.cancel()
 		for {
-			e, err := client.Recv()
+			f, err := client.Recv()
 			if errors.Is(err, io.EOF) {
 				return
 			}
@@ -209,24 +395,134 @@ func (c *Client) PushPull() {
 				c.logger.Error(fmt.Errorf("Client.PushPull(): %w", err).Error())
 				return
 			}
+			if f.GetAck() > 0 {
+				var restore control.Entries
+				for _, e := range f.GetRejected() {
+					c.logger.Error(fmt.Errorf("%w: %s: %s", ErrClientWriteRejected, e.Path(), e.GetReason()).Error())
+					// the rejected paths are set back to the value of the server
+					if e.GetValue() != nil || e.GetRemove() {
+						restore = append(restore, &control.Entry{Key: e.GetKey(), Value: e.GetValue(), Remove: e.GetRemove()})
+					}
+				}
+				if len(restore) > 0 {
+					mu.Lock()
+					_, err = c.combined.AddSetFrom(c.peer.String(), restore)
+					mu.Unlock()
+					if err != nil {
+						c.logger.Warn(fmt.Errorf("Client.PushPull(): %w", err).Error())
+					}
+				}
+				acked.Store(f.GetAck())
+				select {
+				case acks <- struct{}{}:
+				default:
+				}
+			}
+			if f.GetSeq() == 0 {
+				continue
+			}
+
 			mu.Lock()
-			err = c.combined.Add(e)
-			_, _ = c.combined.Entries(c.data)
+			entries := f.GetChanges().GetEntries()
+			if f.GetSnapshot() {
+				// the server does not know what the client has, the entire data is sent again and
+				// what the server no longer has is removed
+				entries, err = c.stale(entries)
+				c.combined.Reset()
+			}
+			// a change set the client sent itself comes back without entries
+			if err == nil && len(entries) > 0 {
+				_, err = c.combined.AddSetFrom(c.peer.String(), entries)
+			}
+			if errors.Is(err, injector.ErrRejected) {
+				// fields not accepted by clients are skipped and the stream is kept open
+				c.logger.Warn(fmt.Errorf("Client.PushPull(): %w", err).Error())
+				err = nil
+			}
+			if err == nil {
+				c.session.applied(f)
+				err = client.Send(&control.Frame{Ack: f.GetSeq()})
+			}
 			mu.Unlock()
 			if err != nil {
+				// none of the entries were applied and the frame is not acknowledged, the server
+				// sends it again after reconnecting
 				c.logger.Error(fmt.Errorf("Client.PushPull(): %w", err).Error())
 				return
 			}
 		}
 	}()
+	if interval, ok := c.settings.AntiEntropy(); ok {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+				case <-c.ctx.Done():
+					return
+				}
+				mu.Lock()
+				// the data only matches the data of the server once the server applied the
+				// entries sent
+				if acked.Load() == sent.Load() {
+					c.repair()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
 	c.logger.Info("Client.PushPull() started")
 
 	wg.Wait()
 	c.logger.Info("Client.PushPull() stopped")
 }
 
+// stale returns the entries of a snapshot of the server preceded by the removals of the subtrees
+// the client has and the snapshot does not.
+func (c *Client) stale(snapshot control.Entries) (control.Entries, error) {
+	tree, err := c.combined.Tree()
+	if err != nil {
+		return nil, err
+	}
+	return append(tree.Stale(merkle.New(snapshot)), snapshot...), nil
+}
+
+// repair compares the data with the data of the server and applies the subtrees that differ, it
+// is skipped while change sets of the server are not all applied.
+func (c *Client) repair() {
+	tree, err := c.combined.Tree()
+	if err != nil {
+		c.logger.Error(fmt.Errorf("Client.repair(): %w", err).Error())
+		return
+	}
+	entries, err := tree.Repair(c.ctx, c.c, func(session string, seq uint64) bool {
+		id, applied := c.session.position()
+		return id == session && applied == seq
+	})
+	if err != nil {
+		c.logger.Error(fmt.Errorf("Client.repair(): %w", err).Error())
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+	c.logger.Info(fmt.Sprintf("Client.repair() repairing %d entries", len(entries)))
+	_, err = c.combined.AddSetFrom(c.peer.String(), entries)
+	if errors.Is(err, injector.ErrRejected) {
+		c.logger.Warn(fmt.Errorf("Client.repair(): %w", err).Error())
+		return
+	}
+	if err != nil {
+		c.logger.Error(fmt.Errorf("Client.repair(): %w", err).Error())
+	}
+}
+
 func (c *Client) Changes() {
-	update, err := c.c.Pull(c.ctx, &control.Request{Type: control.Request_CHANGES})
+	id, resume := c.session.position()
+	update, err := c.c.Pull(c.ctx, &control.Request{Type: control.Request_CHANGES, Session: id, Resume: resume})
 	if err != nil {
 		c.logger.Error(fmt.Errorf("client.changes(): %w", err).Error())
 		return
@@ -245,11 +541,19 @@ func (c *Client) processUpdate(update control.Control_PullClient) {
 			c.cancel()
 			return
 		}
-		err = c.combined.Add(cfg)
+		err = c.combined.AddFrom(c.peer.String(), cfg)
 		if err != nil {
 			c.logger.Error(err.Error())
 		}
 	}
+	// the next request continues after the changes received
+	md := update.Trailer()
+	if ids, seqs := md.Get(control.SessionKey), md.Get(control.SeqKey); len(ids) == 1 && len(seqs) == 1 {
+		seq, err := strconv.ParseUint(seqs[0], 10, 64)
+		if err == nil {
+			c.session.set(ids[0], seq)
+		}
+	}
 }
 
 func (c *Client) closeWithError(err error) error {
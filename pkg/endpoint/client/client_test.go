@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/combined"
+	"github.com/kjbreil/syncer/pkg/endpoint/server"
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+	"google.golang.org/grpc/connectivity"
+)
+
+// TestClient_StopClosesConn verifies the connection to the server is closed once the client stops.
+func TestClient_StopClosesConn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+	errs := make(chan *slog.Record, 100)
+	go func() {
+		for range errs {
+		}
+	}()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := *lis.Addr().(*net.TCPAddr)
+	_ = lis.Close()
+	stngs := &settings.Settings{Port: peer.Port}
+	_, err = server.New(ctx, &wg, &struct{ String string }{}, combined.Shared{}, nil, stngs, errs)
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+
+	var clientWg sync.WaitGroup
+	c, err := New(ctx, &clientWg, &struct{ String string }{}, combined.Shared{}, nil, peer, errs, stngs)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	c.Stop()
+	clientWg.Wait()
+	if state := c.conn.GetState(); state != connectivity.Shutdown {
+		t.Fatalf("connection %s after Stop, want %s", state, connectivity.Shutdown)
+	}
+}
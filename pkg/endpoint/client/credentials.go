@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// reportingCredentials wraps transport credentials so failed handshakes are reported,
+// grpc only surfaces them as an unavailable server.
+type reportingCredentials struct {
+	credentials.TransportCredentials
+	report func(err error)
+}
+
+func (r *reportingCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	c, info, err := r.TransportCredentials.ClientHandshake(ctx, authority, conn)
+	if err != nil && r.report != nil {
+		r.report(err)
+	}
+	return c, info, err
+}
+
+func (r *reportingCredentials) Clone() credentials.TransportCredentials {
+	return &reportingCredentials{
+		TransportCredentials: r.TransportCredentials.Clone(),
+		report:               r.report,
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kjbreil/syncer/pkg/combined"
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
+)
+
+// Session is the state of an endpoint kept between its connections to the server: the last change
+// set of the server it applied and the data as last exchanged with the server. The next client of
+// the endpoint resumes from it instead of receiving the entire data again.
+type Session struct {
+	mu       sync.Mutex
+	id       string
+	seq      uint64
+	combined *combined.Combined
+	// ctx is the context the combined lives as long as
+	ctx context.Context
+}
+
+// NewSession creates a session for an endpoint that did not connect to a server yet.
+func NewSession() *Session {
+	return &Session{}
+}
+
+// open returns the combined of the session, it is created on the first connection and lives as
+// long as ctx. Once ctx is done the next connection creates a new one resuming from the data as
+// last exchanged with the server.
+func (s *Session) open(ctx context.Context, data any, shared combined.Shared) (*combined.Combined, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.combined != nil && s.ctx.Err() == nil {
+		return s.combined, nil
+	}
+	c, err := combined.New(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	c.SetRole(tags.Client)
+	c.Share(shared)
+	if s.combined != nil {
+		err = c.Resume(s.combined)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.combined, s.ctx = c, ctx
+	return c, nil
+}
+
+// position returns the session of the server and the sequence number of its last change set the
+// endpoint applied.
+func (s *Session) position() (string, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id, s.seq
+}
+
+// applied moves the session past the frame once its change set is applied, a snapshot starts the
+// session of the server it came from.
+func (s *Session) applied(f *control.Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f.GetSnapshot() {
+		s.id = f.GetSession()
+	}
+	s.seq = f.GetSeq()
+}
+
+// set moves the session to the change set seq of the server session id.
+func (s *Session) set(id string, seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.id, s.seq = id, seq
+}
@@ -0,0 +1,36 @@
+package endpoint
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/combined"
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+type withPolicy struct {
+	Name  string `syncer:"conflict=longest"`
+	Owned string `syncer:"conflict=server-wins"`
+}
+
+func TestNew_ConflictPolicies(t *testing.T) {
+	longest := map[combined.ConflictPolicy]combined.ConflictResolver{"longest": combined.KeepLocal}
+	tests := []struct {
+		name  string
+		stngs *settings.Settings
+		err   bool
+	}{
+		{"registered", &settings.Settings{Resolvers: longest}, false},
+		{"unknown tag", &settings.Settings{}, true},
+		{"unknown default", &settings.Settings{Resolvers: longest, ConflictPolicy: "shortest"}, true},
+		{"registered default", &settings.Settings{Resolvers: longest, ConflictPolicy: "longest"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&withPolicy{}, tt.stngs)
+			if got := errors.Is(err, combined.ErrConflictPolicy); got != tt.err {
+				t.Fatalf("New() error = %v, want ErrConflictPolicy %t", err, tt.err)
+			}
+		})
+	}
+}
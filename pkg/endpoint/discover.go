@@ -0,0 +1,46 @@
+package endpoint
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/discovery"
+)
+
+// discover announces the endpoint with settings.Settings.Discovery and adds the endpoints found
+// with the same type of data to the peers until the endpoint stops, a transport that is an
+// io.Closer is closed then.
+func (e *Endpoint) discover() {
+	defer e.wg.Done()
+	if closer, ok := e.settings.Discovery.(io.Closer); ok {
+		defer func() {
+			err := closer.Close()
+			if err != nil {
+				e.logger.Error(err.Error())
+			}
+		}()
+	}
+	self := discovery.Announcement{Type: typeName(e.data), ID: e.clock.Origin(), Port: e.settings.Port}
+	discovery.Run(e.ctx, e.settings.Discovery, self, e.settings.Election(), func(peer net.TCPAddr) {
+		if e.AddPeer(peer) {
+			e.logger.Info(fmt.Sprintf("syncer endpoint discovered peer %s", peer.String()))
+		}
+	}, func(err error) {
+		e.logger.Error(err.Error())
+	})
+}
+
+// typeName returns the name of the type of the data with its package path, the endpoints
+// announcing the same name sync with each other.
+func typeName(data any) string {
+	t := reflect.TypeOf(data)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Name() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
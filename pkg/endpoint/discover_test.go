@@ -0,0 +1,135 @@
+package endpoint
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// loopback is a discovery transport delivering every announcement to every endpoint using it, as
+// multicast on the loopback interface would.
+type loopback struct {
+	mu      sync.Mutex
+	inboxes []chan []byte
+}
+
+type loopbackTransport struct {
+	network *loopback
+	inbox   chan []byte
+}
+
+func (l *loopback) transport() *loopbackTransport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inbox := make(chan []byte, 100)
+	l.inboxes = append(l.inboxes, inbox)
+	return &loopbackTransport{network: l, inbox: inbox}
+}
+
+func (t *loopbackTransport) Send(msg []byte) error {
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+	for _, inbox := range t.network.inboxes {
+		select {
+		case inbox <- msg:
+		default:
+		}
+	}
+	return nil
+}
+
+func (t *loopbackTransport) Receive(ctx context.Context) ([]byte, net.IP, error) {
+	select {
+	case msg := <-t.inbox:
+		return msg, net.ParseIP("127.0.0.1"), nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// TestDiscovery verifies endpoints without peers find each other through their announcements and
+// sync, while an endpoint with another type of data is not added to the peers.
+func TestDiscovery(t *testing.T) {
+	network := &loopback{}
+	var eps []*Endpoint
+	var data []*syncStruct
+	for range 2 {
+		d := &syncStruct{}
+		ep, err := New(d, &settings.Settings{
+			Port:             findFreePort(t),
+			Discovery:        network.transport(),
+			AutoUpdate:       true,
+			PollInterval:     20 * time.Millisecond,
+			ElectionInterval: 100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		ep.Run(false)
+		t.Cleanup(ep.Stop)
+		eps = append(eps, ep)
+		data = append(data, d)
+	}
+	other, err := New(&struct{ Other string }{}, &settings.Settings{
+		Port:             findFreePort(t),
+		Discovery:        network.transport(),
+		ElectionInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	other.Run(false)
+	t.Cleanup(other.Stop)
+
+	eps[0].Update(func() { data[0].String = "discovered" })
+	waitForLocked(t, eps[1], func() bool { return data[1].String == "discovered" })
+
+	for _, ep := range eps {
+		for _, peer := range ep.Peers() {
+			if peer.Port == other.settings.Port {
+				t.Fatalf("endpoint of another type added to the peers")
+			}
+		}
+	}
+	if peers := other.Peers(); len(peers) > 0 {
+		t.Fatalf("endpoint of another type found peers %v", peers)
+	}
+}
+
+// closingTransport is a loopback transport recording it was closed.
+type closingTransport struct {
+	*loopbackTransport
+	closed atomic.Bool
+}
+
+func (t *closingTransport) Close() error {
+	t.closed.Store(true)
+	return nil
+}
+
+// TestDiscovery_Close verifies the transport is closed when the endpoint stops.
+func TestDiscovery_Close(t *testing.T) {
+	transport := &closingTransport{loopbackTransport: (&loopback{}).transport()}
+	ep, err := New(&syncStruct{}, &settings.Settings{
+		Port:             findFreePort(t),
+		Discovery:        transport,
+		ElectionInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ep.Run(false)
+	time.Sleep(200 * time.Millisecond)
+	if transport.closed.Load() {
+		t.Fatal("transport closed while the endpoint runs")
+	}
+	ep.Stop()
+	if !transport.closed.Load() {
+		t.Fatal("transport not closed when the endpoint stopped")
+	}
+}
@@ -0,0 +1,92 @@
+// Package discovery announces endpoints on the local network and finds the endpoints syncing the
+// same type of data, so the peers of an endpoint do not have to be known up front.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+var (
+	ErrDiscoveryListen   = errors.New("could not listen for announcements")
+	ErrDiscoveryAnnounce = errors.New("could not announce endpoint")
+	ErrDiscoveryReceive  = errors.New("could not receive announcement")
+)
+
+// DefaultGroup is the multicast group endpoints announce themselves to.
+const DefaultGroup = "239.255.45.12:45012"
+
+// Transport carries the announcements of the endpoints.
+type Transport interface {
+	// Send sends the message to every endpoint on the transport, the sender included.
+	Send(msg []byte) error
+	// Receive blocks until a message is received or ctx is done, it returns the message and the
+	// address of its sender.
+	Receive(ctx context.Context) ([]byte, net.IP, error)
+}
+
+// Announcement is sent by an endpoint to make itself known.
+type Announcement struct {
+	// Type is the type of the data of the endpoint, only endpoints of the same type are peers.
+	Type string `json:"type"`
+	// ID is the ID of the endpoint, an endpoint ignores its own announcements.
+	ID string `json:"id"`
+	// Port is the port the server of the endpoint listens on.
+	Port int `json:"port"`
+}
+
+// Run sends self every interval and calls found with the address of every other endpoint
+// announcing the same type until ctx is done, an endpoint is found again with every announcement.
+// The errors are passed to report until ctx is done. Run returns once it stopped using transport.
+func Run(ctx context.Context, transport Transport, self Announcement, interval time.Duration, found func(net.TCPAddr), report func(error)) {
+	msg, err := json.Marshal(self)
+	if err != nil {
+		report(fmt.Errorf("%w: %w", ErrDiscoveryAnnounce, err))
+		return
+	}
+
+	announcer := make(chan struct{})
+	defer func() { <-announcer }()
+	go func() {
+		defer close(announcer)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			err := transport.Send(msg)
+			if err != nil && ctx.Err() == nil {
+				report(fmt.Errorf("%w: %w", ErrDiscoveryAnnounce, err))
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for ctx.Err() == nil {
+		msg, ip, err := transport.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			report(fmt.Errorf("%w: %w", ErrDiscoveryReceive, err))
+			// a failing transport is not retried at once
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		var a Announcement
+		// messages that are not announcements are ignored, e.g. from other applications in the group
+		if json.Unmarshal(msg, &a) != nil || a.Type != self.Type || a.ID == self.ID || a.Port <= 0 {
+			continue
+		}
+		found(net.TCPAddr{IP: ip, Port: a.Port})
+	}
+}
@@ -0,0 +1,140 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// network delivers every message sent by a member to every member.
+type network struct {
+	mu      sync.Mutex
+	members []*member
+}
+
+type member struct {
+	network *network
+	ip      net.IP
+	inbox   chan message
+}
+
+type message struct {
+	msg []byte
+	ip  net.IP
+}
+
+func (n *network) join(ip string) *member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	m := &member{network: n, ip: net.ParseIP(ip), inbox: make(chan message, 100)}
+	n.members = append(n.members, m)
+	return m
+}
+
+func (m *member) Send(msg []byte) error {
+	m.network.mu.Lock()
+	defer m.network.mu.Unlock()
+	for _, to := range m.network.members {
+		select {
+		case to.inbox <- message{msg: msg, ip: m.ip}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (m *member) Receive(ctx context.Context) ([]byte, net.IP, error) {
+	select {
+	case msg := <-m.inbox:
+		return msg.msg, msg.ip, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// run runs the discovery of an endpoint and returns the addresses it finds, the test ends once
+// the discovery returned.
+func run(t *testing.T, ctx context.Context, transport Transport, self Announcement) <-chan net.TCPAddr {
+	t.Helper()
+	found := make(chan net.TCPAddr, 100)
+	done := make(chan struct{})
+	t.Cleanup(func() { <-done })
+	go func() {
+		defer close(done)
+		Run(ctx, transport, self, 10*time.Millisecond, func(peer net.TCPAddr) {
+			found <- peer
+		}, func(err error) {
+			t.Errorf("discovery error: %v", err)
+		})
+	}()
+	return found
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := &network{}
+	first := run(t, ctx, n.join("10.0.0.1"), Announcement{Type: "main.Data", ID: "first", Port: 1001})
+	second := run(t, ctx, n.join("10.0.0.2"), Announcement{Type: "main.Data", ID: "second", Port: 1002})
+	other := run(t, ctx, n.join("10.0.0.3"), Announcement{Type: "main.Other", ID: "other", Port: 1003})
+	// messages that are not announcements are ignored
+	_ = n.join("10.0.0.4").Send([]byte("hello"))
+
+	for _, want := range []struct {
+		found <-chan net.TCPAddr
+		addr  string
+	}{{first, "10.0.0.2:1002"}, {second, "10.0.0.1:1001"}} {
+		for range 5 {
+			select {
+			case peer := <-want.found:
+				if peer.String() != want.addr {
+					t.Fatalf("found %s, want %s", peer.String(), want.addr)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s not found", want.addr)
+			}
+		}
+	}
+	select {
+	case peer := <-other:
+		t.Fatalf("endpoint of another type found %s", peer.String())
+	default:
+	}
+}
+
+func TestMulticast(t *testing.T) {
+	var transports []*Multicast
+	for range 2 {
+		m, err := NewMulticast(DefaultGroup)
+		if err != nil {
+			t.Skipf("multicast is not available: %v", err)
+		}
+		t.Cleanup(func() { _ = m.Close() })
+		transports = append(transports, m)
+	}
+	if err := transports[0].Send([]byte("probe")); err != nil {
+		t.Skipf("multicast is not available: %v", err)
+	}
+	// the transports are closed once the discovery returned
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	probe, cancelProbe := context.WithTimeout(ctx, 2*time.Second)
+	defer cancelProbe()
+	if _, _, err := transports[1].Receive(probe); err != nil {
+		t.Skipf("multicast is not looped back: %v", err)
+	}
+
+	found := run(t, ctx, transports[0], Announcement{Type: "main.Data", ID: "first", Port: 1001})
+	_ = run(t, ctx, transports[1], Announcement{Type: "main.Data", ID: "second", Port: 1002})
+	select {
+	case peer := <-found:
+		if peer.Port != 1002 {
+			t.Fatalf("found %s, want port 1002", peer.String())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("peer not found")
+	}
+}
@@ -0,0 +1,107 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Multicast is a Transport sending the announcements to a UDP multicast group on the local
+// network.
+type Multicast struct {
+	addr *net.UDPAddr
+
+	// mu guards the connections, they are nil once closed
+	mu     sync.Mutex
+	listen *net.UDPConn
+	send   *net.UDPConn
+}
+
+// NewMulticast joins the multicast group, e.g. DefaultGroup, on the default interface.
+func NewMulticast(group string) (*Multicast, error) {
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscoveryListen, err)
+	}
+	m := &Multicast{addr: addr}
+	_, _, err = m.conns()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// conns returns the connections to the group, joining it again once it was left with Close.
+func (m *Multicast) conns() (*net.UDPConn, *net.UDPConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.listen != nil {
+		return m.listen, m.send, nil
+	}
+	listen, err := net.ListenMulticastUDP("udp4", nil, m.addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrDiscoveryListen, err)
+	}
+	// the announcements are sent from a socket of their own so they loop back to the endpoints
+	// on the same host
+	send, err := net.DialUDP("udp4", nil, m.addr)
+	if err != nil {
+		_ = listen.Close()
+		return nil, nil, fmt.Errorf("%w: %w", ErrDiscoveryListen, err)
+	}
+	m.listen, m.send = listen, send
+	return listen, send, nil
+}
+
+// Send implements Transport.
+func (m *Multicast) Send(msg []byte) error {
+	_, send, err := m.conns()
+	if err != nil {
+		return err
+	}
+	_, err = send.Write(msg)
+	return err
+}
+
+// Receive implements Transport.
+func (m *Multicast) Receive(ctx context.Context) ([]byte, net.IP, error) {
+	listen, _, err := m.conns()
+	if err != nil {
+		return nil, nil, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		// the read is interrupted now and then to notice ctx is done
+		err = listen.SetReadDeadline(time.Now().Add(time.Second))
+		if err != nil {
+			return nil, nil, err
+		}
+		n, from, err := listen.ReadFromUDP(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return buf[:n], from.IP, nil
+	}
+}
+
+// Close leaves the multicast group, the next Send or Receive joins it again.
+func (m *Multicast) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.listen == nil {
+		return nil
+	}
+	err := errors.Join(m.listen.Close(), m.send.Close())
+	m.listen, m.send = nil, nil
+	return err
+}
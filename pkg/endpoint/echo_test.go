@@ -0,0 +1,76 @@
+package endpoint
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// TestEndpoints_NoPingPong verifies a change is received once by the other endpoint and never sent
+// back, while both endpoints keep polling.
+func TestEndpoints_NoPingPong(t *testing.T) {
+	port := findFreePort(t)
+
+	serverData := &syncStruct{String: "start"}
+	serverEP, err := New(serverData, &settings.Settings{Port: port, AutoUpdate: true, PollInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	var serverChanges atomic.Int32
+	if err = serverEP.Subscribe("String", func(Change) { serverChanges.Add(1) }); err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:         port + 1,
+		Peers:        []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate:   true,
+		PollInterval: 20 * time.Millisecond,
+		// a repair comparing equal data changes nothing
+		AntiEntropyInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	var clientChanges atomic.Int32
+	if err = clientEP.Subscribe("String", func(Change) { clientChanges.Add(1) }); err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP)
+
+	// wait for one change on each side, then make sure no more arrive
+	wait := func(changes *atomic.Int32, want int32) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for changes.Load() < want && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if got := changes.Load(); got != want {
+			t.Fatalf("got %d changes, want %d", got, want)
+		}
+	}
+	wait(&clientChanges, 1)
+	clientEP.Update(func() { clientData.String = "client" })
+	wait(&serverChanges, 1)
+	serverEP.Update(func() { serverData.String = "server" })
+	wait(&clientChanges, 2)
+	if serverChanges.Load() != 1 {
+		t.Fatalf("server got %d changes, want 1", serverChanges.Load())
+	}
+
+	clientEP.RLock()
+	defer clientEP.RUnlock()
+	if clientData.String != "server" {
+		t.Fatalf("client String = %q, want server", clientData.String)
+	}
+}
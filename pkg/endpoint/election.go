@@ -0,0 +1,192 @@
+package endpoint
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/endpoint/client"
+	"github.com/kjbreil/syncer/pkg/endpoint/server"
+)
+
+// election is the state of the endpoint in the leader election. Of the endpoints that can reach
+// each other the one ranked highest by control.Leader.Outranks is the server: an endpoint finding
+// only servers ranked lower asks them to step down and becomes the server, a server finding one
+// ranked higher steps down. Every server elected starts a new term.
+type election struct {
+	mu       sync.Mutex
+	id       string
+	priority int64
+	term     uint64
+	// an endpoint waits for a candidate taking over until waitUntil instead of becoming the server
+	wait      time.Duration
+	waitUntil time.Time
+}
+
+func newElection(id string, priority int64, wait time.Duration) *election {
+	return &election{id: id, priority: priority, wait: wait}
+}
+
+// Leader returns the endpoint as the server, it implements server.Election.
+func (el *election) Leader() *control.Leader {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	return &control.Leader{ID: el.id, Priority: el.priority, Term: el.term}
+}
+
+// StepDown steps down for a candidate ranked higher with a newer term, it implements
+// server.Election.
+func (el *election) StepDown(candidate *control.Leader) bool {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	self := &control.Leader{ID: el.id, Priority: el.priority}
+	if candidate.GetTerm() <= el.term || !candidate.Outranks(self) {
+		return false
+	}
+	el.term = candidate.GetTerm()
+	el.waitUntil = time.Now().Add(el.wait)
+	return true
+}
+
+// hold keeps the endpoint from becoming the server while another candidate takes over.
+func (el *election) hold() {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	el.waitUntil = time.Now().Add(el.wait)
+}
+
+// observe moves the term up to the term of the server.
+func (el *election) observe(server *control.Leader) {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	el.term = max(el.term, server.GetTerm())
+}
+
+// candidate returns the endpoint as the candidate for the term following the term of the server.
+func (el *election) candidate(server *control.Leader) *control.Leader {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	el.term = max(el.term, server.GetTerm())
+	return &control.Leader{ID: el.id, Priority: el.priority, Term: el.term + 1}
+}
+
+// lead starts the next term with the endpoint as the server.
+func (el *election) lead() uint64 {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	el.term++
+	el.waitUntil = time.Time{}
+	return el.term
+}
+
+// waiting reports if the endpoint waits for a candidate to become the server.
+func (el *election) waiting() bool {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	return time.Now().Before(el.waitUntil)
+}
+
+// isSelf reports if the peer is the address the endpoint listens on.
+func (e *Endpoint) isSelf(peer net.TCPAddr) bool {
+	if peer.Port != e.settings.Port {
+		return false
+	}
+	if peer.IP.IsLoopback() || peer.IP.IsUnspecified() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(peer.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// leaders probes the peers and returns the reachable servers and standbys by the index of the
+// peer.
+func (e *Endpoint) leaders(peers []net.TCPAddr) map[int]*control.Leader {
+	leaders := make(map[int]*control.Leader)
+	for i, peer := range peers {
+		if e.isSelf(peer) {
+			continue
+		}
+		leader, err := client.Probe(e.ctx, peer, e.settings)
+		if errors.Is(err, client.ErrClientHandshake) || errors.Is(err, client.ErrClientRejected) {
+			e.logger.Error(err.Error())
+		}
+		e.reach(peer, err == nil)
+		if err != nil {
+			continue
+		}
+		leaders[i] = leader
+	}
+	return leaders
+}
+
+// rank returns the index of the server ranked highest, -1 without a server, and if a standby ranks
+// higher than the endpoint.
+func (e *Endpoint) rank(leaders map[int]*control.Leader) (int, bool) {
+	self := e.election.Leader()
+	best, deferred := -1, false
+	for i, leader := range leaders {
+		if leader.GetStandby() {
+			deferred = deferred || leader.Outranks(self)
+			continue
+		}
+		if best < 0 || leaders[best] != nil && (leader == nil || leader.Outranks(leaders[best])) {
+			best = i
+		}
+	}
+	return best, deferred
+}
+
+// standBy starts answering the election while the endpoint is not the server.
+func (e *Endpoint) standBy() {
+	if e.server != nil || e.standby != nil && e.standby.Running() {
+		return
+	}
+	st, err := server.NewStandby(e.ctx, e.wg, e.election, e.settings, e.Errors)
+	if err != nil {
+		// the port is still held by the server that stopped, the next try takes it
+		return
+	}
+	e.standby = st
+}
+
+// stopStandby frees the port of the standby for the server.
+func (e *Endpoint) stopStandby() {
+	if e.standby != nil {
+		e.standby.Stop()
+		e.standby = nil
+	}
+}
+
+// settle looks for other servers, the server ranked lower steps down.
+func (e *Endpoint) settle() {
+	e.resolve()
+	peers := e.Peers()
+	for i, leader := range e.leaders(peers) {
+		self := e.election.Leader()
+		if leader.GetStandby() {
+			// a standby ranked higher asks the server to step down itself
+			continue
+		}
+		if leader == nil || leader.Outranks(self) {
+			e.logger.Info(fmt.Sprintf("server stepping down for %s", peers[i].String()))
+			e.election.observe(leader)
+			e.server.Stop()
+			return
+		}
+		ok, _, err := client.Elect(e.ctx, peers[i], e.settings, e.election.candidate(leader))
+		if err == nil && ok {
+			e.election.lead()
+		}
+	}
+}
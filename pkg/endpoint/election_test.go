@@ -0,0 +1,139 @@
+package endpoint
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// waitForLeader waits until the endpoint at want is the only server and every other running
+// endpoint is its client.
+func waitForLeader(t *testing.T, eps []*Endpoint, want int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var state string
+		ok := true
+		for i, ep := range eps {
+			if ep == nil {
+				continue
+			}
+			state += fmt.Sprintf(" %d: server %t running %t term %d", i, ep.IsServer(), ep.Running(), ep.Term())
+			if ep.IsServer() != (i == want) || !ep.Running() {
+				ok = false
+			}
+		}
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("endpoint %d not elected:%s", want, state)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// TestElection verifies the endpoint with the highest priority becomes the server even when
+// started last, and the next one takes over once it stops.
+func TestElection(t *testing.T) {
+	var peers []net.TCPAddr
+	for range 3 {
+		peers = append(peers, net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: findFreePort(t)})
+	}
+	eps := make([]*Endpoint, len(peers))
+	for i, peer := range peers {
+		ep, err := New(&syncStruct{}, &settings.Settings{
+			Port:             peer.Port,
+			Peers:            peers,
+			AutoUpdate:       true,
+			Priority:         int64(i),
+			ElectionInterval: 100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		eps[i] = ep
+	}
+
+	eps[0].Run(false)
+	defer eps[0].Stop()
+	waitForLeader(t, eps[:1], 0)
+	first := eps[0].Term()
+
+	eps[1].Run(false)
+	defer eps[1].Stop()
+	eps[2].Run(false)
+	waitForLeader(t, eps, 2)
+	if eps[2].Term() <= first {
+		t.Fatalf("term %d not after term %d", eps[2].Term(), first)
+	}
+
+	eps[2].Stop()
+	eps[2] = nil
+	waitForLeader(t, eps, 1)
+}
+
+// TestElection_SingleServer verifies that endpoints started together, and the clients left when
+// the server stops, never run two servers at the same time.
+func TestElection_SingleServer(t *testing.T) {
+	var peers []net.TCPAddr
+	for range 3 {
+		peers = append(peers, net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: findFreePort(t)})
+	}
+	eps := make([]*Endpoint, len(peers))
+	for i, peer := range peers {
+		ep, err := New(&syncStruct{}, &settings.Settings{
+			Port:             peer.Port,
+			Peers:            peers,
+			AutoUpdate:       true,
+			Priority:         int64(i),
+			ElectionInterval: 100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		eps[i] = ep
+	}
+
+	var servers atomic.Int32
+	done := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			var n int32
+			for _, ep := range eps {
+				if ep.IsServer() {
+					n++
+				}
+			}
+			servers.Store(max(servers.Load(), n))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for _, ep := range eps {
+		ep.Run(false)
+	}
+	defer eps[0].Stop()
+	defer eps[1].Stop()
+	waitForLeader(t, eps, 2)
+
+	eps[2].Stop()
+	waitForLeader(t, eps[:2], 1)
+
+	close(done)
+	<-watched
+	if n := servers.Load(); n > 1 {
+		t.Fatalf("%d endpoints were the server at the same time", n)
+	}
+}
@@ -3,24 +3,31 @@ package endpoint
 import (
 	"context"
 	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log/slog"
 	"math/big"
 	"net"
 	"os"
 	"reflect"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/kjbreil/syncer/pkg/combined"
+	"github.com/kjbreil/syncer/pkg/control"
 	"github.com/kjbreil/syncer/pkg/endpoint/client"
 	"github.com/kjbreil/syncer/pkg/endpoint/server"
 	settings2 "github.com/kjbreil/syncer/pkg/endpoint/settings"
+	"github.com/kjbreil/syncer/pkg/persist"
 )
 
 var (
 	ErrNotPointer               = errors.New("data must be a pointer")
 	ErrClientAlreadyConnected   = errors.New("client already connected")
 	ErrClientServerNonAvailable = errors.New("client could not find available server to connect to")
+	ErrResolvePeer              = errors.New("could not resolve peer")
 )
 
 // Endpoint contains both the server and the Client
@@ -30,12 +37,48 @@ type Endpoint struct {
 	// port   int `extractor:"-"`
 	// peers  []net.TCPAddr
 	settings *settings2.Settings
-	localIP  []net.IP
 	server   *server.Server    `extractor:"-"`
 	client   *client.Client    `extractor:"-"`
 	data     any               `extractor:"-"`
 	Errors   chan *slog.Record `extractor:"-"`
 	logger   *slog.Logger      `extractor:"-"`
+	// mu guards the data, it is held by the injector while applying changes from peers and by
+	// the extractor while copying the data
+	mu *sync.RWMutex
+	// subscriptions are shared with the server and client combined
+	subscriptions *combined.Subscriptions
+	// journal records every change when a log file is configured
+	journal *persist.Log
+	// session is resumed by every client of the endpoint
+	session *client.Session
+	// election decides if the endpoint is the server, the standby answers it while the endpoint
+	// is not the server
+	election *election
+	standby  *server.Standby
+	// peers are the peers of settings.Settings.Peers and the peers added, resolved or discovered
+	// since, reachable holds if a peer was reachable when last tried and links the cancel func of
+	// the link of a mesh to every peer, all by the address of the peer
+	peers     []net.TCPAddr
+	reachable map[string]bool
+	links     map[string]context.CancelFunc
+	// resolved are the addresses of the names of settings.Settings.Hosts, a name is stale when one
+	// of its addresses failed
+	resolved map[string][]net.TCPAddr
+	stale    map[string]bool
+	// sources tells where every peer came from, resolving a name again only removes the peers
+	// that came from resolving it
+	sources map[string]source
+	peersMu sync.RWMutex
+	// peerEvents is called when a peer becomes reachable or unreachable
+	peerEvents func(PeerEvent)
+	// positions are the change sets of every peer of a mesh applied, by the address of the peer
+	positions map[string]*server.Position
+	// clock stamps the writes of the endpoint, it is shared with the server and client combined
+	clock *combined.Clock
+	// conflicts is called for the conflicting writes from peers
+	conflicts func(Conflict)
+	// state guards server and client, they are only changed by the run goroutine and Stop
+	state sync.RWMutex
 
 	ctx      context.Context    `extractor:"-"`
 	cancel   context.CancelFunc `extractor:"-"`
@@ -46,6 +89,7 @@ type Endpoint struct {
 // New creates a new Endpoint with the given data and settings.
 // The data must be a pointer to a struct.
 // If the data is not a pointer, an error is returned.
+// When persistence is configured the saved snapshot is loaded into the data.
 func New(data any, stngs *settings2.Settings) (*Endpoint, error) {
 	if data == nil {
 		return nil, errors.New("data cannot be nil")
@@ -59,6 +103,15 @@ func New(data any, stngs *settings2.Settings) (*Endpoint, error) {
 		return nil, errors.New("settings cannot be nil")
 	}
 
+	// a policy without a resolver would silently resolve like the default one
+	err := combined.CheckPolicies(data, stngs.Resolvers)
+	if p := stngs.ConflictPolicy; p != "" && !p.Known(stngs.Resolvers) {
+		err = errors.Join(err, fmt.Errorf("%w: %s", combined.ErrConflictPolicy, p))
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	ep := &Endpoint{
@@ -66,11 +119,41 @@ func New(data any, stngs *settings2.Settings) (*Endpoint, error) {
 		server:   nil,
 		client:   nil,
 		data:     data,
+		mu:       &sync.RWMutex{},
 		ctx:      ctx,
 		cancel:   cancel,
 		wg:       &sync.WaitGroup{},
 		Errors:   make(chan *slog.Record, 100),
 		logger:   slog.New(slog.NewTextHandler(os.Stdout, nil)),
+
+		subscriptions: combined.NewSubscriptions(),
+		session:       client.NewSession(),
+		positions:     make(map[string]*server.Position),
+		peers:         slices.Clone(stngs.Peers),
+		reachable:     make(map[string]bool),
+		links:         make(map[string]context.CancelFunc),
+		resolved:      make(map[string][]net.TCPAddr),
+		stale:         make(map[string]bool),
+		sources:       make(map[string]source),
+	}
+	for _, peer := range stngs.Peers {
+		ep.sources[peer.String()] |= peerConfigured
+	}
+
+	id := stngs.ID
+	if id == "" {
+		b := make([]byte, 8)
+		_, _ = rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	ep.clock = combined.NewClock(id)
+	ep.election = newElection(id, stngs.Priority, 2*stngs.Election())
+
+	// the persisted data is loaded before connecting to any peer
+	err = ep.restore()
+	if err != nil {
+		cancel()
+		return nil, err
 	}
 
 	return ep, nil
@@ -82,10 +165,21 @@ func (e *Endpoint) Run(onlyClient bool) {
 	if e.Running() {
 		return
 	}
+	// Stop closes the log, a restarted endpoint records the changes again
+	err := e.openJournal()
+	if err != nil {
+		e.logger.Error(err.Error())
+	}
 	e.ctx, e.cancel = context.WithCancel(context.Background())
 	// add two WG because there are two goroutines started in e.run
 	e.wg.Add(2)
 	go e.run(onlyClient)
+	e.wg.Add(1)
+	go e.snapshots(e.ctx)
+	if e.settings.Discovery != nil {
+		e.wg.Add(1)
+		go e.discover()
+	}
 	// for !e.Running() {
 	// 	time.Sleep(100 * time.Millisecond)
 	// }
@@ -93,7 +187,14 @@ func (e *Endpoint) Run(onlyClient bool) {
 
 // IsServer returns true if the endpoint is running as a server.
 func (e *Endpoint) IsServer() bool {
-	return e.server != nil
+	e.state.RLock()
+	defer e.state.RUnlock()
+	return e.server != nil && e.server.Running()
+}
+
+// Term returns the term of the last server elected the endpoint knows of.
+func (e *Endpoint) Term() uint64 {
+	return e.election.Leader().GetTerm()
 }
 
 // Wait blocks until the endpoint is stopped.
@@ -117,12 +218,9 @@ func (e *Endpoint) AddHandler(state State, handler func() error) {
 }
 
 func (e *Endpoint) run(onlyClient bool) {
-	e.localIP = nil
-
 	var err error
-	e.ctx, e.cancel = context.WithCancel(context.Background())
-	checkPeersDuration := time.Minute
-	checkPeersLast := time.Now()
+	interval := e.settings.Election()
+	settled := time.Now()
 
 	go func() {
 		for {
@@ -136,95 +234,118 @@ func (e *Endpoint) run(onlyClient bool) {
 		}
 	}()
 
+	if e.settings.Mesh {
+		e.runMesh()
+		e.wg.Done()
+		return
+	}
+
 	for {
 		if e.ctx.Err() != nil {
 			e.wg.Done()
 			return
 		}
+		if !onlyClient {
+			e.standBy()
+		}
 		if !e.Running() {
-			err = e.tryPeers(false)
+			err = e.tryPeers(onlyClient)
 			if err == nil {
 				e.clientStarted()
 			}
-			if errors.Is(err, ErrClientServerNonAvailable) && !onlyClient {
-				e.server, err = server.New(e.ctx, e.wg, e.data, e.settings, e.Errors)
+			// a server that stepped down waits for the endpoint it stepped down for
+			if errors.Is(err, ErrClientServerNonAvailable) && !onlyClient && !e.election.waiting() {
+				// the server listens on the port of the standby
+				e.stopStandby()
+				var srv *server.Server
+				srv, err = server.New(e.ctx, e.wg, e.data, e.shared(), e.election, e.settings, e.Errors)
 
 				if err == nil {
+					term := e.election.lead()
+					e.setServer(srv)
 					e.serverStarted()
-					checkPeersLast = time.Now()
-
-					ifaces, err := net.Interfaces()
-					if err != nil {
-						continue
-					}
-					e.localIP = nil
-					// handle err
-					for _, i := range ifaces {
-						addrs, err := i.Addrs()
-						if err != nil {
-							continue
-						}
-						for _, addr := range addrs {
-							var ip net.IP
-							switch v := addr.(type) {
-							case *net.IPNet:
-								ip = v.IP
-							case *net.IPAddr:
-								ip = v.IP
-							}
-							e.localIP = append(e.localIP, ip)
-							// process IP address
-						}
-					}
+					e.logger.Info(fmt.Sprintf("syncer endpoint elected server for term %d", term))
+					settled = time.Now()
 				}
 			}
 		}
 		// check if the Client exists but the context is canceled
 		if e.client != nil && !e.client.Running() {
 			e.serverStopped()
-			e.localIP = nil
-			e.client = nil
+			e.setClient(nil)
 		}
 		if e.server != nil && !e.server.Running() {
 			e.serverStopped()
-			e.localIP = nil
-			e.server = nil
+			e.setServer(nil)
 		}
-		if e.server != nil && time.Since(checkPeersLast) > checkPeersDuration {
-			e.logger.Info("testing for other servers")
-			checkPeersLast = time.Now()
-			_ = e.tryPeers(true)
+		if e.server != nil && time.Since(settled) > interval {
+			settled = time.Now()
+			e.settle()
 		}
 
-		// try and connect to peers using random milliseconds between 100 and 1000
-		time.Sleep(time.Duration(randomInt(100, 1000)) * time.Millisecond)
+		// look for a server again at a random time so endpoints started together do not probe
+		// each other at the same time
+		time.Sleep(min(time.Duration(randomInt(100, 1000))*time.Millisecond, interval))
 	}
 }
 
-func (e *Endpoint) tryPeers(stop bool) error {
-	var err error
+// tryPeers connects to the server ranked highest among the peers. When the endpoint ranks higher
+// than every server found and may become the server itself, the servers are asked to step down and
+// ErrClientServerNonAvailable is returned. The endpoint defers to every standby ranked higher, it
+// becomes the server or asks the servers to step down instead.
+func (e *Endpoint) tryPeers(onlyClient bool) error {
 	if e.client != nil {
 		return ErrClientAlreadyConnected
 	}
-peerLoop:
-	for _, peer := range e.settings.Peers {
-		for _, ip := range e.localIP {
-			if ip.Equal(peer.IP) {
-				continue peerLoop
-			}
+	e.resolve()
+	peers := e.Peers()
+	leaders := e.leaders(peers)
+	best, deferred := e.rank(leaders)
+	if best < 0 && !deferred && !onlyClient && !e.election.waiting() {
+		// endpoints started together may not listen yet, look once more before becoming the server
+		select {
+		case <-e.ctx.Done():
+			return e.ctx.Err()
+		case <-time.After(min(e.settings.Election()/2, time.Second)):
 		}
-		e.client, err = client.New(e.ctx, e.wg, e.data, peer, e.Errors, e.settings)
-		if err == nil {
-			if stop {
-				e.client.ShutdownRemoteServer()
+		leaders = e.leaders(peers)
+		best, deferred = e.rank(leaders)
+	}
+	if deferred {
+		e.election.hold()
+	}
+	if best < 0 {
+		return ErrClientServerNonAvailable
+	}
+	if leader := leaders[best]; leader != nil && !onlyClient && !deferred && e.election.Leader().Outranks(leader) {
+		for i, leader := range leaders {
+			if leader.GetStandby() {
 				continue
 			}
-			e.client.Init()
-			return nil
+			ok, current, err := client.Elect(e.ctx, peers[i], e.settings, e.election.candidate(leader))
+			if err != nil || !ok {
+				// the server did not step down or another candidate was faster, wait for it
+				e.election.observe(current)
+				e.election.hold()
+			}
 		}
+		return ErrClientServerNonAvailable
+	}
+
+	e.election.observe(leaders[best])
+	cl, err := client.New(e.ctx, e.wg, e.data, e.shared(), e.session, peers[best], e.Errors, e.settings)
+	if err != nil {
 		// TODO: Check error for if there is an injector problem (return error) or not available (continue)
+		return ErrClientServerNonAvailable
 	}
-	return ErrClientServerNonAvailable
+	e.setClient(cl)
+	e.wg.Add(1)
+	go e.watch(cl)
+	// PushPull resumes the session or receives the entire data
+	if !e.settings.AutoUpdate {
+		cl.Init()
+	}
+	return nil
 }
 
 // Stop stops the Endpoint.
@@ -233,22 +354,154 @@ func (e *Endpoint) Stop() {
 	e.cancel()
 	e.logger.Info("syncer endpoint stopped")
 	e.wg.Wait()
-	e.client = nil
-	e.server = nil
+	e.setClient(nil)
+	e.setServer(nil)
+	err := e.SaveSnapshot()
+	if err != nil {
+		e.logger.Error(err.Error())
+	}
+	e.closeJournal()
 }
 
 // Running returns true if the endpoint is running.
 func (e *Endpoint) Running() bool {
+	e.state.RLock()
+	defer e.state.RUnlock()
 	return e.server != nil || e.client != nil
 }
 
+func (e *Endpoint) setServer(s *server.Server) {
+	e.state.Lock()
+	defer e.state.Unlock()
+	e.server = s
+}
+
+func (e *Endpoint) setClient(c *client.Client) {
+	e.state.Lock()
+	defer e.state.Unlock()
+	e.client = c
+}
+
+// shared returns the state shared with the combined of the server and client.
+func (e *Endpoint) shared() combined.Shared {
+	shared := combined.Shared{
+		Mutex:         e.mu,
+		Subscriptions: e.subscriptions,
+		Clock:         e.clock,
+		Resolvers:     e.settings.Resolvers,
+		Conflicts:     e.conflicts,
+	}
+	if p := e.settings.ConflictPolicy; p != "" {
+		shared.Resolver = p
+		if r, ok := e.settings.Resolvers[p]; ok {
+			shared.Resolver = r
+		}
+	}
+	if e.journal != nil {
+		shared.Journal = e.journal
+	}
+	return shared
+}
+
 // ClientUpdate sends any changes made by the client to the server.
 func (e *Endpoint) ClientUpdate() {
+	e.state.RLock()
+	c := e.client
+	e.state.RUnlock()
+	if c != nil {
+		c.Changes()
+	}
+}
+
+// Notify sends the changes made to the data to the connected peers immediately instead of
+// waiting for the next poll, see settings.Settings.PollInterval.
+func (e *Endpoint) Notify() {
+	e.state.RLock()
+	defer e.state.RUnlock()
+	if e.server != nil {
+		e.server.Notify()
+	}
 	if e.client != nil {
-		e.client.Changes()
+		e.client.Notify()
 	}
 }
 
+// Update calls fn to change the data while holding the lock and sends the changes to the
+// connected peers.
+func (e *Endpoint) Update(fn func()) {
+	e.With(fn)
+	e.Notify()
+}
+
+// Change describes a change received from a peer, see Subscribe.
+type Change = combined.Change
+
+// Subscribe calls fn for every change received from a peer at, below or above path. Fields are
+// separated by a dot and indexes are enclosed in brackets, * matches any field or index, e.g.
+// Map[*].Status. fn is called from the goroutine applying the change and must not block.
+func (e *Endpoint) Subscribe(path string, fn func(Change)) error {
+	pattern, err := control.NewPattern(path)
+	if err != nil {
+		return err
+	}
+	e.subscriptions.Add(pattern, fn)
+	return nil
+}
+
+// SubscribeSet calls fn once for every set of changes received from a peer together, with the
+// changes matching path as described by Subscribe. fn must not block.
+func (e *Endpoint) SubscribeSet(path string, fn func([]Change)) error {
+	pattern, err := control.NewPattern(path)
+	if err != nil {
+		return err
+	}
+	e.subscriptions.AddSet(pattern, fn)
+	return nil
+}
+
+// Conflict describes a write from a peer made without knowing about a local write to the same
+// path, see OnConflict.
+type Conflict = combined.Conflict
+
+// OnConflict calls fn for every write from a peer conflicting with a local write, after the
+// write to keep was chosen by settings.Settings.ConflictPolicy. It must be called before Run,
+// fn is called from the goroutine applying the change and must not block.
+func (e *Endpoint) OnConflict(fn func(Conflict)) {
+	e.conflicts = fn
+}
+
+// ID returns the ID the writes of the endpoint are stamped with.
+func (e *Endpoint) ID() string {
+	return e.clock.Origin()
+}
+
+// Lock locks the data for writing, changes from peers are not applied until Unlock is called.
+func (e *Endpoint) Lock() {
+	e.mu.Lock()
+}
+
+// Unlock unlocks the data locked by Lock.
+func (e *Endpoint) Unlock() {
+	e.mu.Unlock()
+}
+
+// RLock locks the data for reading.
+func (e *Endpoint) RLock() {
+	e.mu.RLock()
+}
+
+// RUnlock unlocks the data locked by RLock.
+func (e *Endpoint) RUnlock() {
+	e.mu.RUnlock()
+}
+
+// With calls fn while holding the lock of the data.
+func (e *Endpoint) With(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fn()
+}
+
 // randomInt returns a random integer between l and h, inclusive.
 // If random generation fails, it returns the middle of the low/high.
 func randomInt(l, h int) int {
@@ -0,0 +1,142 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// pull requests the changes after seq of the session and returns them with the position after them.
+func pull(ctx context.Context, t *testing.T, client control.ControlClient, req *control.Request) (control.Entries, string, uint64) {
+	t.Helper()
+	stream, err := client.Pull(ctx, req)
+	if err != nil {
+		t.Fatalf("Pull() error: %v", err)
+	}
+	var entries control.Entries
+	for {
+		e, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	md := stream.Trailer()
+	seq, err := strconv.ParseUint(md.Get(control.SeqKey)[0], 10, 64)
+	if err != nil {
+		t.Fatalf("trailer %v: %v", md, err)
+	}
+	return entries, md.Get(control.SessionKey)[0], seq
+}
+
+// TestServer_EveryClientReceivesEveryChange verifies every connected client receives every change
+// set once, whichever client asks for changes first.
+func TestServer_EveryClientReceivesEveryChange(t *testing.T) {
+	port := findFreePort(t)
+	data := &syncStruct{String: "one", Int: 1}
+	ep, err := New(data, &settings.Settings{Port: port, PollInterval: -1})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ep.Run(false)
+	defer ep.Stop()
+	waitForServer(t, ep)
+
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	defer conn.Close()
+	client := control.NewControlClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	streams := []control.Control_PushPullClient{openStream(ctx, t, client, "", 0), openStream(ctx, t, client, "", 0)}
+	for _, stream := range streams {
+		recvAck(t, stream)
+	}
+	// a client pulling the entire data and then only the changes
+	_, session, seq := pull(ctx, t, client, &control.Request{Type: control.Request_INIT})
+
+	for i := 2; i <= 4; i++ {
+		ep.Update(func() { data.Int = i })
+		for n, stream := range streams {
+			f := recvAck(t, stream)
+			entries := f.GetChanges().GetEntries()
+			if len(entries) != 1 || entries[0].Path() != "Int" || entries[0].GetValue().GetInt64() != int64(i) {
+				t.Fatalf("stream %d: expected Int %d, got %v", n, i, entries)
+			}
+		}
+		entries, _, next := pull(ctx, t, client, &control.Request{Type: control.Request_CHANGES, Session: session, Resume: seq})
+		if len(entries) != 1 || entries[0].GetValue().GetInt64() != int64(i) || next != seq+1 {
+			t.Fatalf("pull after %d: expected Int %d, got %v at %d", seq, i, entries, next)
+		}
+		seq = next
+	}
+
+	entries, _, next := pull(ctx, t, client, &control.Request{Type: control.Request_CHANGES, Session: session, Resume: seq})
+	if len(entries) != 0 || next != seq {
+		t.Fatalf("expected no changes after %d, got %v at %d", seq, entries, next)
+	}
+}
+
+// TestServer_ForwardsClientChanges verifies the server forwards the change set of one client to the
+// other clients and only moves the sender past it.
+func TestServer_ForwardsClientChanges(t *testing.T) {
+	port := findFreePort(t)
+	data := &syncStruct{String: "one", Int: 1}
+	ep, err := New(data, &settings.Settings{Port: port, PollInterval: -1})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ep.Run(false)
+	defer ep.Stop()
+	waitForServer(t, ep)
+
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	defer conn.Close()
+	client := control.NewControlClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a, b := openStream(ctx, t, client, "", 0), openStream(ctx, t, client, "", 0)
+	snapshot := recvAck(t, a)
+	recvAck(t, b)
+
+	e := control.NewEntry(2, "from a")
+	e.Key = []*control.Key{{Key: "syncStruct"}, {Key: "String"}}
+	err = a.Send(&control.Frame{Seq: 1, Changes: &control.ChangeSet{Entries: control.Entries{e}}})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+
+	f := recvAck(t, b)
+	entries := f.GetChanges().GetEntries()
+	if f.GetSeq() != snapshot.GetSeq()+1 || len(entries) != 1 || entries[0].GetValue().GetString_() != "from a" {
+		t.Fatalf("expected the change set of a, got %v", f)
+	}
+	f = recvAck(t, a)
+	if f.GetSeq() != snapshot.GetSeq()+1 || len(f.GetChanges().GetEntries()) != 0 {
+		t.Fatalf("expected a frame without entries for the sender, got %v", f)
+	}
+	ep.RLock()
+	defer ep.RUnlock()
+	if data.String != "from a" {
+		t.Fatalf("server data String = %q, want %q", data.String, "from a")
+	}
+}
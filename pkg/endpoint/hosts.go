@@ -0,0 +1,143 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// resolveTimeout bounds the lookups of resolve, a slow name server does not hold up the endpoint
+// looking for a server.
+const resolveTimeout = 2 * time.Second
+
+// resolve resolves the names of settings.Settings.Hosts not resolved yet or with an address that
+// failed when last tried. The addresses a name resolved to before are replaced in the peers, unless
+// they are peers for another reason.
+func (e *Endpoint) resolve() {
+	e.peersMu.RLock()
+	var hosts []string
+	for _, host := range e.settings.Hosts {
+		if _, ok := e.resolved[host]; !ok || e.stale[host] {
+			hosts = append(hosts, host)
+		}
+	}
+	e.peersMu.RUnlock()
+	if len(hosts) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(e.ctx, resolveTimeout)
+	defer cancel()
+	for _, host := range hosts {
+		addrs, err := resolveHost(ctx, e.settings.Lookup(), host)
+		if err != nil {
+			e.logger.Error(fmt.Errorf("%w: %s: %w", ErrResolvePeer, host, err).Error())
+			continue
+		}
+		e.peersMu.Lock()
+		previous := e.resolved[host]
+		e.resolved[host] = addrs
+		delete(e.stale, host)
+		e.peersMu.Unlock()
+
+		for _, addr := range previous {
+			if e.unresolved(addr) {
+				e.RemovePeer(addr)
+			}
+		}
+		for _, addr := range addrs {
+			e.addPeer(addr, peerResolved)
+		}
+	}
+}
+
+// unresolved reports if the address is no longer a peer once no name resolves to it, it is kept when
+// it was configured or added as well.
+func (e *Endpoint) unresolved(addr net.TCPAddr) bool {
+	e.peersMu.Lock()
+	defer e.peersMu.Unlock()
+	for _, addrs := range e.resolved {
+		if slices.ContainsFunc(addrs, func(a net.TCPAddr) bool { return samePeer(a, addr) }) {
+			return false
+		}
+	}
+	src, ok := e.sources[addr.String()]
+	if !ok {
+		return false
+	}
+	e.sources[addr.String()] = src &^ peerResolved
+	return src&^peerResolved == 0
+}
+
+// failed marks the names resolved to the address as stale, the caller holds peersMu.
+func (e *Endpoint) failed(peer net.TCPAddr) {
+	for host, addrs := range e.resolved {
+		if slices.ContainsFunc(addrs, func(a net.TCPAddr) bool { return samePeer(a, peer) }) {
+			e.stale[host] = true
+		}
+	}
+}
+
+// resolveHost returns the addresses of host, either host:port or the name of a DNS SRV record
+// starting with an underscore.
+func resolveHost(ctx context.Context, resolver settings.HostResolver, host string) ([]net.TCPAddr, error) {
+	if strings.HasPrefix(host, "_") {
+		_, srvs, err := resolver.LookupSRV(ctx, "", "", host)
+		if err != nil {
+			return nil, err
+		}
+		var addrs []net.TCPAddr
+		for _, srv := range srvs {
+			ips, err := lookup(ctx, resolver, strings.TrimSuffix(srv.Target, "."))
+			if err != nil {
+				return nil, err
+			}
+			for _, ip := range ips {
+				addrs = append(addrs, net.TCPAddr{IP: ip, Port: int(srv.Port)})
+			}
+		}
+		return addrs, nil
+	}
+
+	name, p, err := net.SplitHostPort(host)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := lookup(ctx, resolver, name)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]net.TCPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.TCPAddr{IP: ip, Port: port})
+	}
+	return addrs, nil
+}
+
+// lookup returns the IPs of the host name, an IP is returned as is.
+func lookup(ctx context.Context, resolver settings.HostResolver, name string) ([]net.IP, error) {
+	if ip := net.ParseIP(name); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	hosts, err := resolver.LookupHost(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
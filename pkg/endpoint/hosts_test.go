@@ -0,0 +1,214 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+var errNotFound = errors.New("not found")
+
+// fakeResolver resolves the names it holds, the records can be changed while it is used.
+type fakeResolver struct {
+	mu    sync.Mutex
+	hosts map[string][]string
+	srvs  map[string][]*net.SRV
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, errNotFound
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if srvs, ok := r.srvs[name]; ok {
+		return name, srvs, nil
+	}
+	return "", nil, errNotFound
+}
+
+func (r *fakeResolver) setSRV(name string, srvs ...*net.SRV) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.srvs[name] = srvs
+}
+
+func TestResolveHost(t *testing.T) {
+	resolver := &fakeResolver{
+		hosts: map[string][]string{"server.test": {"10.0.0.1", "10.0.0.2"}, "a.test": {"10.0.0.3"}},
+		srvs:  map[string][]*net.SRV{"_syncer._tcp.test": {{Target: "a.test.", Port: 1001}, {Target: "10.0.0.4", Port: 1002}}},
+	}
+	tests := []struct {
+		host    string
+		want    []string
+		wantErr bool
+	}{
+		{host: "server.test:45012", want: []string{"10.0.0.1:45012", "10.0.0.2:45012"}},
+		{host: "10.0.0.9:1", want: []string{"10.0.0.9:1"}},
+		{host: "_syncer._tcp.test", want: []string{"10.0.0.3:1001", "10.0.0.4:1002"}},
+		{host: "missing.test:1", wantErr: true},
+		{host: "_missing._tcp.test", wantErr: true},
+		{host: "server.test", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			addrs, err := resolveHost(context.Background(), resolver, tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveHost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, addr := range addrs {
+				got = append(got, addr.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("resolveHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHosts_Reresolve verifies a client given an SRV name connects to the server the record points
+// to once the address it resolved to first fails and the name is resolved again.
+func TestHosts_Reresolve(t *testing.T) {
+	port := findFreePort(t)
+	serverData := &syncStruct{String: "server"}
+	serverEP, err := New(serverData, &settings.Settings{Port: port, AutoUpdate: true, PollInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+
+	unused := findFreePort(t)
+	resolver := &fakeResolver{
+		hosts: map[string][]string{"server.test": {"127.0.0.1"}},
+		srvs:  map[string][]*net.SRV{"_syncer._tcp.test": {{Target: "server.test.", Port: uint16(unused)}}},
+	}
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:             findFreePort(t),
+		Hosts:            []string{"_syncer._tcp.test"},
+		HostResolver:     resolver,
+		AutoUpdate:       true,
+		PollInterval:     20 * time.Millisecond,
+		ElectionInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+
+	stale := net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: unused}
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.ContainsFunc(clientEP.Peers(), func(p net.TCPAddr) bool { return samePeer(p, stale) }) {
+		if time.Now().After(deadline) {
+			t.Fatal("name not resolved")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the server moved, the record is updated
+	resolver.setSRV("_syncer._tcp.test", &net.SRV{Target: "server.test.", Port: uint16(port)})
+	waitForRunning2(t, clientEP)
+	waitForLocked(t, clientEP, func() bool { return clientData.String == "server" })
+	want := net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+	if peers := clientEP.Peers(); len(peers) != 1 || !samePeer(peers[0], want) {
+		t.Fatalf("Peers() = %v, want %v", peers, want)
+	}
+}
+
+// TestHosts_Sources verifies an address a name no longer resolves to stays a peer when it was
+// configured or added as well.
+func TestHosts_Sources(t *testing.T) {
+	resolver := &fakeResolver{hosts: map[string][]string{"a.test": {"10.0.0.1"}, "b.test": {"10.0.0.2"}}}
+	configured := net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1}
+	ep, err := New(&syncStruct{}, &settings.Settings{
+		Peers:        []net.TCPAddr{configured},
+		Hosts:        []string{"a.test:1", "b.test:1"},
+		HostResolver: resolver,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	added := net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1}
+	ep.resolve()
+	if ep.AddPeer(added) {
+		t.Fatal("AddPeer() added a resolved peer")
+	}
+
+	// both names move, the old addresses were peers before they were resolved
+	resolver.mu.Lock()
+	resolver.hosts = map[string][]string{"a.test": {"10.0.0.3"}, "b.test": {"10.0.0.4"}}
+	resolver.mu.Unlock()
+	ep.peersMu.Lock()
+	ep.stale["a.test:1"], ep.stale["b.test:1"] = true, true
+	ep.peersMu.Unlock()
+	ep.resolve()
+
+	var got []string
+	for _, peer := range ep.Peers() {
+		got = append(got, peer.String())
+	}
+	if want := []string{"10.0.0.1:1", "10.0.0.2:1", "10.0.0.3:1", "10.0.0.4:1"}; !slices.Equal(got, want) {
+		t.Fatalf("Peers() = %v, want %v", got, want)
+	}
+
+	// a name that moves again only drops the address resolved from it
+	resolver.mu.Lock()
+	resolver.hosts["a.test"] = []string{"10.0.0.5"}
+	resolver.mu.Unlock()
+	ep.peersMu.Lock()
+	ep.stale["a.test:1"] = true
+	ep.peersMu.Unlock()
+	ep.resolve()
+	got = got[:0]
+	for _, peer := range ep.Peers() {
+		got = append(got, peer.String())
+	}
+	if want := []string{"10.0.0.1:1", "10.0.0.2:1", "10.0.0.4:1", "10.0.0.5:1"}; !slices.Equal(got, want) {
+		t.Fatalf("Peers() = %v, want %v", got, want)
+	}
+}
+
+// blockingResolver answers no lookup until ctx is done.
+type blockingResolver struct{}
+
+func (blockingResolver) LookupHost(ctx context.Context, _ string) ([]string, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingResolver) LookupSRV(ctx context.Context, _, _, _ string) (string, []*net.SRV, error) {
+	<-ctx.Done()
+	return "", nil, ctx.Err()
+}
+
+// TestHosts_ResolveTimeout verifies a name server that does not answer does not hold up resolve.
+func TestHosts_ResolveTimeout(t *testing.T) {
+	ep, err := New(&syncStruct{}, &settings.Settings{
+		Hosts:        []string{"a.test:1", "_syncer._tcp.test"},
+		HostResolver: blockingResolver{},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	start := time.Now()
+	ep.resolve()
+	if d := time.Since(start); d > 2*resolveTimeout {
+		t.Fatalf("resolve() took %s", d)
+	}
+}
@@ -0,0 +1,102 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/client"
+	"github.com/kjbreil/syncer/pkg/endpoint/server"
+)
+
+// runMesh keeps the server of the endpoint running with a link to every peer until the endpoint
+// stops, see settings.Settings.Mesh. The peers link to the server of the endpoint in turn so
+// every endpoint forwards the change sets it receives to the others.
+func (e *Endpoint) runMesh() {
+	interval := e.settings.Election()
+	for e.ctx.Err() == nil {
+		if e.server != nil && !e.server.Running() {
+			e.serverStopped()
+			e.setServer(nil)
+		}
+		if e.server == nil {
+			srv, err := server.New(e.ctx, e.wg, e.data, e.shared(), nil, e.settings, e.Errors)
+			if err == nil {
+				e.setServer(srv)
+				e.serverStarted()
+				e.logger.Info("syncer endpoint started mesh server")
+				e.unlinkAll()
+			}
+		}
+		if e.server != nil {
+			// peers added later are linked to as well
+			e.resolve()
+			for _, peer := range e.Peers() {
+				if e.isSelf(peer) {
+					continue
+				}
+				ctx, ok := e.linkTo(peer)
+				if !ok {
+					continue
+				}
+				if e.positions[peer.String()] == nil {
+					e.positions[peer.String()] = &server.Position{}
+				}
+				e.wg.Add(1)
+				go e.link(ctx, e.server, peer, e.positions[peer.String()])
+			}
+		}
+		select {
+		case <-time.After(interval):
+		case <-e.ctx.Done():
+		}
+	}
+}
+
+// linkTo returns the context of a new link to the peer and false when the server links to it
+// already, the link is closed when the peer is removed.
+func (e *Endpoint) linkTo(peer net.TCPAddr) (context.Context, bool) {
+	e.peersMu.Lock()
+	defer e.peersMu.Unlock()
+	if _, ok := e.links[peer.String()]; ok {
+		return nil, false
+	}
+	ctx, cancel := context.WithCancel(e.ctx)
+	e.links[peer.String()] = cancel
+	return ctx, true
+}
+
+// unlinkAll closes the links of the server.
+func (e *Endpoint) unlinkAll() {
+	e.peersMu.Lock()
+	defer e.peersMu.Unlock()
+	for peer, cancel := range e.links {
+		cancel()
+		delete(e.links, peer)
+	}
+}
+
+// link keeps a link from the server to the peer open while the server runs and until ctx is done,
+// the change sets of the peer are resumed after position.
+func (e *Endpoint) link(ctx context.Context, srv *server.Server, peer net.TCPAddr, position *server.Position) {
+	defer e.wg.Done()
+	for srv.Running() && ctx.Err() == nil {
+		conn, err := client.Dial(ctx, peer, e.settings)
+		e.reach(peer, err == nil)
+		if err == nil {
+			err = srv.Link(ctx, conn, peer.String(), position)
+			_ = conn.Close()
+		}
+		// an unreachable peer is tried again quietly
+		if err != nil && !errors.Is(err, client.ErrClientNotAvailable) {
+			e.logger.Error(fmt.Errorf("syncer endpoint link: %w", err).Error())
+		}
+		select {
+		case <-time.After(e.settings.Election()):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
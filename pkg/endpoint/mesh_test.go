@@ -0,0 +1,105 @@
+package endpoint
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// startMesh starts an endpoint in mesh mode for every address, linking to the peers returned for
+// it, and counts the changes of String every endpoint receives.
+func startMesh(t *testing.T, addrs []net.TCPAddr, peers func(i int) []net.TCPAddr) ([]*Endpoint, []*syncStruct, []*atomic.Int32) {
+	t.Helper()
+	eps := make([]*Endpoint, len(addrs))
+	data := make([]*syncStruct, len(addrs))
+	changes := make([]*atomic.Int32, len(addrs))
+	for i, addr := range addrs {
+		data[i] = &syncStruct{}
+		ep, err := New(data[i], &settings.Settings{
+			Port:             addr.Port,
+			Peers:            peers(i),
+			Mesh:             true,
+			AutoUpdate:       true,
+			PollInterval:     20 * time.Millisecond,
+			ElectionInterval: 100 * time.Millisecond,
+			// the links compare their data while changes are exchanged
+			AntiEntropyInterval: 50 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		changes[i] = &atomic.Int32{}
+		count := changes[i]
+		if err = ep.Subscribe("String", func(Change) { count.Add(1) }); err != nil {
+			t.Fatalf("Subscribe() error: %v", err)
+		}
+		ep.Run(false)
+		t.Cleanup(ep.Stop)
+		eps[i] = ep
+	}
+	for _, ep := range eps {
+		waitForServer(t, ep)
+	}
+	return eps, data, changes
+}
+
+// meshAddrs returns n loopback addresses with free ports.
+func meshAddrs(t *testing.T, n int) []net.TCPAddr {
+	var addrs []net.TCPAddr
+	for range n {
+		addrs = append(addrs, net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: findFreePort(t)})
+	}
+	return addrs
+}
+
+// TestMesh_NoLoops verifies a change made on one endpoint of a full mesh is received exactly once
+// by every other endpoint and never comes back, although every endpoint links to every other.
+func TestMesh_NoLoops(t *testing.T) {
+	addrs := meshAddrs(t, 3)
+	eps, data, changes := startMesh(t, addrs, func(int) []net.TCPAddr { return addrs })
+
+	for _, change := range []struct {
+		ep    int
+		value string
+	}{{0, "first"}, {2, "second"}, {1, "third"}} {
+		before := make([]int32, len(eps))
+		for i := range eps {
+			before[i] = changes[i].Load()
+		}
+		eps[change.ep].Update(func() { data[change.ep].String = change.value })
+		for i, ep := range eps {
+			waitForLocked(t, ep, func() bool { return data[i].String == change.value })
+		}
+		// copies taking the other paths through the mesh are dropped
+		time.Sleep(300 * time.Millisecond)
+		for i := range eps {
+			want := int32(1)
+			if i == change.ep {
+				want = 0
+			}
+			if got := changes[i].Load() - before[i]; got != want {
+				t.Fatalf("change %q: endpoint %d got %d changes, want %d", change.value, i, got, want)
+			}
+		}
+	}
+}
+
+// TestMesh_ForwardsThroughPeers verifies endpoints linked only to a common peer receive the
+// changes of each other through it.
+func TestMesh_ForwardsThroughPeers(t *testing.T) {
+	addrs := meshAddrs(t, 3)
+	eps, data, _ := startMesh(t, addrs, func(i int) []net.TCPAddr {
+		if i == 1 {
+			return nil
+		}
+		return addrs[1:2]
+	})
+
+	eps[0].Update(func() { data[0].String = "from first" })
+	waitForLocked(t, eps[2], func() bool { return data[2].String == "from first" })
+	eps[2].Update(func() { data[2].Int = 3 })
+	waitForLocked(t, eps[0], func() bool { return data[0].Int == 3 })
+}
@@ -107,7 +107,9 @@ func TestNetworkSync_AllTypes(t *testing.T) {
 	// Wait for data to sync (Init is called inside tryPeers on successful connection)
 	time.Sleep(2 * time.Second)
 
-	// Verify synced data
+	// Verify synced data while holding the locks the injectors write under
+	serverEP.RLock()
+	clientEP.RLock()
 	if clientData.String != serverData.String {
 		t.Errorf("String: got %q, want %q", clientData.String, serverData.String)
 	}
@@ -175,6 +177,9 @@ func TestNetworkSync_AllTypes(t *testing.T) {
 		t.Errorf("Sub.Name: got %q, want %q", clientData.Sub.Name, serverData.Sub.Name)
 	}
 
+	clientEP.RUnlock()
+	serverEP.RUnlock()
+
 	// Cleanup
 	serverEP.Stop()
 	clientEP.Stop()
@@ -0,0 +1,86 @@
+package endpoint
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// TestNotify_WithoutPolling verifies changes are only sent when notified when polling is disabled.
+func TestNotify_WithoutPolling(t *testing.T) {
+	port := findFreePort(t)
+
+	serverData := &syncStruct{String: "initial"}
+	serverEP, err := New(serverData, &settings.Settings{
+		Port:         port,
+		AutoUpdate:   true,
+		PollInterval: -1,
+	})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+	time.Sleep(500 * time.Millisecond)
+
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:         port + 1,
+		Peers:        []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate:   true,
+		PollInterval: -1,
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP)
+	time.Sleep(500 * time.Millisecond)
+
+	if !readLocked(clientEP, func() bool { return clientData.String == "initial" }) {
+		t.Fatal("client did not receive initial data")
+	}
+
+	serverEP.With(func() {
+		serverData.Int = 1
+	})
+	time.Sleep(1500 * time.Millisecond)
+	if !readLocked(clientEP, func() bool { return clientData.Int == 0 }) {
+		t.Fatal("change sent without notify while polling is disabled")
+	}
+
+	serverEP.Notify()
+	waitFor(t, func() bool {
+		return readLocked(clientEP, func() bool { return clientData.Int == 1 })
+	}, "notified server change not received by client")
+
+	clientEP.Update(func() {
+		clientData.Int8 = 8
+	})
+	waitFor(t, func() bool {
+		return readLocked(serverEP, func() bool { return serverData.Int8 == 8 })
+	}, "updated client change not received by server")
+}
+
+// readLocked calls fn while holding the read lock of the endpoint.
+func readLocked(ep *Endpoint, fn func() bool) bool {
+	ep.RLock()
+	defer ep.RUnlock()
+	return fn()
+}
+
+// waitFor fails the test when cond does not become true within 500 milliseconds.
+func waitFor(t *testing.T, cond func() bool, problem string) {
+	t.Helper()
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(problem)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,127 @@
+package endpoint
+
+import (
+	"net"
+	"slices"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/client"
+)
+
+// PeerEvent tells a peer became reachable or unreachable, see OnPeer.
+type PeerEvent struct {
+	Peer      net.TCPAddr
+	Reachable bool
+}
+
+// OnPeer calls fn when a peer becomes reachable or unreachable, as found when the endpoint probes
+// the peers looking for a server, links to them in a mesh or its client disconnects. It must be
+// called before Run, fn is called from the goroutine trying the peer and must not block.
+func (e *Endpoint) OnPeer(fn func(PeerEvent)) {
+	e.peerEvents = fn
+}
+
+// Peers returns the peers of the endpoint: the peers of settings.Settings.Peers, the addresses of
+// settings.Settings.Hosts and the peers added or discovered since.
+func (e *Endpoint) Peers() []net.TCPAddr {
+	e.peersMu.RLock()
+	defer e.peersMu.RUnlock()
+	return slices.Clone(e.peers)
+}
+
+// source tells where a peer came from, a peer can come from several sources.
+type source uint8
+
+const (
+	// peerConfigured is a peer of settings.Settings.Peers
+	peerConfigured source = 1 << iota
+	// peerAdded is a peer added with AddPeer or discovered
+	peerAdded
+	// peerResolved is an address of a name of settings.Settings.Hosts
+	peerResolved
+)
+
+// AddPeer adds the peer while the endpoint runs, it is tried the next time the endpoint looks for a
+// server and linked to in a mesh. It reports if the peer was added, false when it is known already.
+func (e *Endpoint) AddPeer(peer net.TCPAddr) bool {
+	return e.addPeer(peer, peerAdded)
+}
+
+// addPeer adds the peer from src, a known peer only records the source.
+func (e *Endpoint) addPeer(peer net.TCPAddr, src source) bool {
+	e.peersMu.Lock()
+	defer e.peersMu.Unlock()
+	e.sources[peer.String()] |= src
+	if slices.ContainsFunc(e.peers, func(known net.TCPAddr) bool { return samePeer(known, peer) }) {
+		return false
+	}
+	e.peers = append(e.peers, peer)
+	return true
+}
+
+// RemovePeer removes the peer while the endpoint runs, a client connected to it disconnects and
+// looks for another server and the link of a mesh to it is closed. It reports if the peer was
+// removed, false when it is not known.
+func (e *Endpoint) RemovePeer(peer net.TCPAddr) bool {
+	e.peersMu.Lock()
+	i := slices.IndexFunc(e.peers, func(known net.TCPAddr) bool { return samePeer(known, peer) })
+	if i < 0 {
+		e.peersMu.Unlock()
+		return false
+	}
+	e.peers = slices.Delete(e.peers, i, i+1)
+	delete(e.sources, peer.String())
+	delete(e.reachable, peer.String())
+	if cancel, ok := e.links[peer.String()]; ok {
+		cancel()
+		delete(e.links, peer.String())
+	}
+	e.peersMu.Unlock()
+
+	e.state.RLock()
+	defer e.state.RUnlock()
+	if e.client != nil && samePeer(e.client.Peer(), peer) {
+		e.client.Stop()
+	}
+	return true
+}
+
+// reach records if the peer was reachable when tried and calls the OnPeer func when that changed.
+func (e *Endpoint) reach(peer net.TCPAddr, reachable bool) {
+	e.peersMu.Lock()
+	if !slices.ContainsFunc(e.peers, func(known net.TCPAddr) bool { return samePeer(known, peer) }) {
+		// the peer was removed while it was tried
+		e.peersMu.Unlock()
+		return
+	}
+	was, known := e.reachable[peer.String()]
+	e.reachable[peer.String()] = reachable
+	if !reachable {
+		e.failed(peer)
+	}
+	e.peersMu.Unlock()
+	if (!known || was != reachable) && e.peerEvents != nil {
+		e.peerEvents(PeerEvent{Peer: peer, Reachable: reachable})
+	}
+}
+
+// watch probes the server of the client once the client disconnects, the server going down is
+// reported right away instead of the next time the endpoint looks for a server.
+func (e *Endpoint) watch(cl *client.Client) {
+	defer e.wg.Done()
+	select {
+	case <-cl.Done():
+	case <-e.ctx.Done():
+		return
+	}
+	if e.ctx.Err() != nil {
+		return
+	}
+	peer := cl.Peer()
+	_, err := client.Probe(e.ctx, peer, e.settings)
+	e.reach(peer, err == nil)
+}
+
+// samePeer reports if the addresses are the same.
+func samePeer(a, b net.TCPAddr) bool {
+	return a.IP.Equal(b.IP) && a.Port == b.Port && a.Zone == b.Zone
+}
@@ -0,0 +1,145 @@
+package endpoint
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// TestPeers_AddRemove verifies a client without peers connects to a peer added at runtime, is
+// told when peers become reachable or unreachable and disconnects when the peer is removed.
+func TestPeers_AddRemove(t *testing.T) {
+	port := findFreePort(t)
+	serverData := &syncStruct{String: "server"}
+	serverEP, err := New(serverData, &settings.Settings{Port: port, AutoUpdate: true, PollInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:             findFreePort(t),
+		AutoUpdate:       true,
+		PollInterval:     20 * time.Millisecond,
+		ElectionInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	var mu sync.Mutex
+	events := make(map[string]bool)
+	clientEP.OnPeer(func(event PeerEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events[event.Peer.String()] = event.Reachable
+	})
+	reachable := func(peer net.TCPAddr) (bool, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		r, ok := events[peer.String()]
+		return r, ok
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+
+	server := net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+	unused := net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: findFreePort(t)}
+	if !clientEP.AddPeer(unused) || !clientEP.AddPeer(server) {
+		t.Fatalf("AddPeer() did not add the peers")
+	}
+	if clientEP.AddPeer(server) {
+		t.Fatalf("AddPeer() added a known peer")
+	}
+	waitForRunning2(t, clientEP)
+	waitForLocked(t, clientEP, func() bool { return clientData.String == "server" })
+	if r, ok := reachable(server); !ok || !r {
+		t.Fatalf("server reachable = %v, %v, want true", r, ok)
+	}
+	if r, ok := reachable(unused); !ok || r {
+		t.Fatalf("unused peer reachable = %v, %v, want false", r, ok)
+	}
+
+	if !clientEP.RemovePeer(server) {
+		t.Fatalf("RemovePeer() did not remove the server")
+	}
+	if clientEP.RemovePeer(server) {
+		t.Fatalf("RemovePeer() removed an unknown peer")
+	}
+	if peers := clientEP.Peers(); len(peers) != 1 || !samePeer(peers[0], unused) {
+		t.Fatalf("Peers() = %v, want %v", peers, unused)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for clientEP.Running() {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not disconnect from the removed peer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a peer that stops becomes unreachable
+	clientEP.AddPeer(server)
+	waitForRunning2(t, clientEP)
+	serverEP.Stop()
+	deadline = time.Now().Add(10 * time.Second)
+	for r, _ := reachable(server); r; r, _ = reachable(server) {
+		if time.Now().After(deadline) {
+			t.Fatal("stopped server still reachable")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestPeers_ClientDisconnect verifies the server of a client going down is reported as soon as the
+// client disconnects, not the next time the endpoint looks for a server.
+func TestPeers_ClientDisconnect(t *testing.T) {
+	port := findFreePort(t)
+	serverEP, err := New(&syncStruct{}, &settings.Settings{Port: port, AutoUpdate: true, PollInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+
+	server := net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+	clientEP, err := New(&syncStruct{}, &settings.Settings{
+		Port:             findFreePort(t),
+		Peers:            []net.TCPAddr{server},
+		AutoUpdate:       true,
+		PollInterval:     20 * time.Millisecond,
+		ElectionInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	unreachable := make(chan struct{}, 1)
+	clientEP.OnPeer(func(event PeerEvent) {
+		if !event.Reachable {
+			select {
+			case unreachable <- struct{}{}:
+			default:
+			}
+		}
+	})
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP)
+
+	serverEP.Stop()
+	stopped := time.Now()
+	select {
+	case <-unreachable:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopped server still reachable")
+	}
+	// the probe of the stopped server times out, the endpoint looks for a server again later
+	if d := time.Since(stopped); d > time.Second {
+		t.Fatalf("server reported unreachable after %s", d)
+	}
+}
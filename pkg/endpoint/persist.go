@@ -0,0 +1,143 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/persist"
+)
+
+var (
+	ErrRestore  = errors.New("could not restore persisted data")
+	ErrSnapshot = errors.New("could not write snapshot")
+)
+
+// restore replaces the data with the snapshot and replays the log on top of it, a missing snapshot
+// is not an error. The log is kept open to record the changes.
+func (e *Endpoint) restore() error {
+	p := e.settings.Persistence
+	if p == nil {
+		return nil
+	}
+
+	var seq uint64
+	if p.SnapshotFile != "" {
+		var entries control.Entries
+		var err error
+		seq, entries, err = persist.ReadSnapshot(p.SnapshotFile)
+		switch {
+		case err == nil:
+			// the snapshot holds the data from its zero value
+			persist.Zero(e.data, e.mu)
+		case !errors.Is(err, os.ErrNotExist):
+			return fmt.Errorf("%w: %w", ErrRestore, err)
+		}
+		err = persist.Restore(e.data, e.mu, entries)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrRestore, err)
+		}
+	}
+
+	err := e.openJournal()
+	if err != nil || e.journal == nil {
+		return err
+	}
+	var entries control.Entries
+	err = e.journal.Replay(seq, func(entry *control.Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err == nil {
+		err = persist.Restore(e.data, e.mu, entries)
+	}
+	if err != nil {
+		e.closeJournal()
+		return fmt.Errorf("%w: %w", ErrRestore, err)
+	}
+	return nil
+}
+
+// openJournal opens the log file when one is configured and the log is not open yet.
+func (e *Endpoint) openJournal() error {
+	p := e.settings.Persistence
+	if p == nil || p.LogFile == "" || e.journal != nil {
+		return nil
+	}
+	journal, err := persist.OpenLog(p.LogFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRestore, err)
+	}
+	e.journal = journal
+	return nil
+}
+
+// closeJournal closes the log, nothing is recorded until it is opened again.
+func (e *Endpoint) closeJournal() {
+	if e.journal == nil {
+		return
+	}
+	err := e.journal.Close()
+	if err != nil {
+		e.logger.Error(err.Error())
+	}
+	e.journal = nil
+}
+
+// SaveSnapshot writes the data to the snapshot file and trims the log, it does nothing when no
+// snapshot file is configured.
+func (e *Endpoint) SaveSnapshot() error {
+	p := e.settings.Persistence
+	if p == nil || p.SnapshotFile == "" {
+		return nil
+	}
+	// every record up to seq is in the data copied after reading it
+	var seq uint64
+	if e.journal != nil {
+		seq = e.journal.Seq()
+	}
+	entries, err := persist.Snapshot(e.data, e.mu.RLocker())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSnapshot, err)
+	}
+	err = persist.WriteSnapshot(p.SnapshotFile, seq, entries)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSnapshot, err)
+	}
+	if e.journal != nil {
+		err = e.journal.Compact(seq)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrSnapshot, err)
+		}
+	}
+	return nil
+}
+
+// snapshots writes the snapshot at the configured interval until ctx is done.
+func (e *Endpoint) snapshots(ctx context.Context) {
+	defer e.wg.Done()
+	p := e.settings.Persistence
+	if p == nil || p.SnapshotFile == "" {
+		return
+	}
+	interval, ok := p.Snapshots()
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := e.SaveSnapshot()
+			if err != nil {
+				e.logger.Error(err.Error())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
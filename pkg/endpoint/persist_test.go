@@ -0,0 +1,191 @@
+package endpoint
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// TestPersistence_Restart verifies the data saved when an endpoint stops is loaded by a new
+// endpoint and that snapshots are written while running.
+func TestPersistence_Restart(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.snap")
+	stngs := func() *settings.Settings {
+		return &settings.Settings{
+			Port: findFreePort(t),
+			Persistence: &settings.Persistence{
+				SnapshotFile:     file,
+				SnapshotInterval: 100 * time.Millisecond,
+			},
+		}
+	}
+
+	data := &syncStruct{String: "saved", Map: map[string]int{"a": 1}, Sub: subStruct{Name: "sub"}}
+	ep, err := New(data, stngs())
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ep.Run(false)
+	waitForServer(t, ep)
+
+	ep.With(func() { data.Int = 5 })
+	time.Sleep(300 * time.Millisecond)
+	scheduled := &syncStruct{}
+	_, err = New(scheduled, stngs())
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if scheduled.Int != 5 {
+		t.Fatalf("scheduled snapshot not written: %+v", scheduled)
+	}
+
+	ep.With(func() { data.Int = 6 })
+	ep.Stop()
+
+	// the snapshot replaces the data the endpoint is created with
+	restored := &syncStruct{Bool: true, Map: map[string]int{"stale": 1}}
+	_, err = New(restored, stngs())
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if restored.String != "saved" || restored.Int != 6 || restored.Map["a"] != 1 || restored.Sub.Name != "sub" || restored.Bool || len(restored.Map) != 1 {
+		t.Fatalf("data not restored: %+v", restored)
+	}
+}
+
+// TestPersistence_LogReplay verifies changes received from a peer are recovered from the log
+// when the endpoint did not stop cleanly.
+func TestPersistence_LogReplay(t *testing.T) {
+	port := findFreePort(t)
+	dir := t.TempDir()
+
+	serverData := &syncStruct{String: "server", Map: map[string]int{"a": 1}}
+	serverEP, err := New(serverData, &settings.Settings{Port: port, AutoUpdate: true, PollInterval: -1})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+	time.Sleep(500 * time.Millisecond)
+
+	// without a snapshot file stopping writes no snapshot, everything comes from the log
+	stngs := &settings.Settings{
+		Port:         port + 1,
+		Peers:        []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate:   true,
+		PollInterval: -1,
+		Persistence: &settings.Persistence{
+			LogFile: filepath.Join(dir, "data.log"),
+		},
+	}
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, stngs)
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP)
+
+	serverEP.Update(func() { serverData.Int = 7 })
+	waitForLocked(t, clientEP, func() bool { return clientData.Int == 7 })
+
+	clientEP.Stop()
+
+	recovered := &syncStruct{}
+	recoveredEP, err := New(recovered, stngs)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer recoveredEP.Stop()
+	if recovered.String != "server" || recovered.Int != 7 || recovered.Map["a"] != 1 {
+		t.Fatalf("data not recovered from the log: %+v", recovered)
+	}
+}
+
+// TestPersistence_CrashAfterRestart verifies a change made after a clean restart is recovered from
+// the log when the endpoint then crashes, the log keeps counting after the snapshot.
+func TestPersistence_CrashAfterRestart(t *testing.T) {
+	stngs := func(dir string) *settings.Settings {
+		return &settings.Settings{
+			Port:         findFreePort(t),
+			AutoUpdate:   true,
+			PollInterval: 20 * time.Millisecond,
+			Persistence: &settings.Persistence{
+				SnapshotFile:     filepath.Join(dir, "data.snap"),
+				SnapshotInterval: -1,
+				LogFile:          filepath.Join(dir, "data.log"),
+			},
+		}
+	}
+	dir := t.TempDir()
+	journaled := func(ep *Endpoint, seq uint64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for ep.journal.Seq() <= seq {
+			if time.Now().After(deadline) {
+				t.Fatal("change not written to the log")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	data := &syncStruct{String: "saved"}
+	ep, err := New(data, stngs(dir))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ep.Run(false)
+	waitForServer(t, ep)
+	// more records than the restored data makes up
+	var seq uint64
+	for i := 1; i <= 10; i++ {
+		ep.With(func() { data.Int = i })
+		journaled(ep, seq)
+		seq = ep.journal.Seq()
+	}
+	ep.Stop()
+
+	restarted := &syncStruct{}
+	ep, err = New(restarted, stngs(dir))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ep.Run(false)
+	defer ep.Stop()
+	waitForServer(t, ep)
+	// the server records the restored data first
+	time.Sleep(200 * time.Millisecond)
+	if ep.journal.Seq() < seq {
+		t.Fatalf("log restarted at %d after the snapshot of %d", ep.journal.Seq(), seq)
+	}
+	seq = ep.journal.Seq()
+	ep.With(func() { restarted.Int = 2 })
+	journaled(ep, seq)
+
+	// the files as a crash leaves them, the endpoint wrote no snapshot since the restart
+	crashed := t.TempDir()
+	for _, name := range []string{"data.snap", "data.log"} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(crashed, name), b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recovered := &syncStruct{}
+	recoveredEP, err := New(recovered, stngs(crashed))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer recoveredEP.Stop()
+	if recovered.String != "saved" || recovered.Int != 2 {
+		t.Fatalf("data not recovered from the log: %+v", recovered)
+	}
+}
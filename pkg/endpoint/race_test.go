@@ -0,0 +1,85 @@
+package endpoint
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// TestConcurrentEdits changes the data on both sides while it is being synchronized, run with
+// -race to verify the application, extractor and injector only touch the data under the lock.
+func TestConcurrentEdits(t *testing.T) {
+	port := findFreePort(t)
+
+	serverData := &syncStruct{Map: map[string]int{}}
+	serverEP, err := New(serverData, &settings.Settings{
+		Port:         port,
+		AutoUpdate:   true,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+	time.Sleep(500 * time.Millisecond)
+
+	clientData := &syncStruct{Map: map[string]int{}}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:         port + 1,
+		Peers:        []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate:   true,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP)
+	time.Sleep(500 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	edit := func(ep *Endpoint, data *syncStruct, fn func(i int)) {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			ep.Update(func() { fn(i) })
+			readLocked(ep, func() bool {
+				return len(data.Map) > 0
+			})
+			time.Sleep(time.Millisecond)
+		}
+	}
+	wg.Add(2)
+	go edit(serverEP, serverData, func(i int) {
+		serverData.Int = i
+		serverData.Map["server"+strconv.Itoa(i%10)] = i
+	})
+	go edit(clientEP, clientData, func(i int) {
+		clientData.Int8 = int8(i % 100)
+		clientData.Map["client"+strconv.Itoa(i%10)] = i
+	})
+	wg.Wait()
+
+	serverEP.Update(func() { serverData.String = "server done" })
+	waitForLocked(t, clientEP, func() bool { return clientData.String == "server done" })
+	clientEP.Update(func() { clientData.Uint = 1 })
+	waitForLocked(t, serverEP, func() bool { return serverData.Uint == 1 })
+}
+
+// waitForLocked waits up to five seconds for cond to be true, cond is called holding the read lock.
+func waitForLocked(t *testing.T, ep *Endpoint, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !readLocked(ep, cond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/endpoint/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamActions maps the streaming methods of the Control service to their action.
+var streamActions = map[string]auth.Action{
+	control.Control_Pull_FullMethodName:     auth.Pull,
+	control.Control_Push_FullMethodName:     auth.Push,
+	control.Control_PushPull_FullMethodName: auth.PushPull,
+	control.Control_Subtrees_FullMethodName: auth.Pull,
+}
+
+// authenticate identifies the peer making the request and checks it may perform the action.
+// The returned context carries the identity of the peer.
+func (s *Server) authenticate(ctx context.Context, action auth.Action) (context.Context, error) {
+	var identity string
+	if s.authenticator != nil {
+		var err error
+		identity, err = s.authenticator.Authenticate(ctx)
+		if err != nil {
+			s.logger.Warn("Server: rejected peer " + auth.PeerIdentity(ctx) + ": " + err.Error())
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+	} else {
+		identity = auth.PeerIdentity(ctx)
+	}
+
+	if s.authorize != nil && !s.authorize(identity, action) {
+		s.logger.Warn("Server: denied " + action.String() + " to " + identity)
+		return nil, status.Errorf(codes.PermissionDenied, "%s may not %s", identity, action)
+	}
+
+	return auth.NewContext(ctx, identity), nil
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	// only the methods of the Control service are authenticated, reflection is left open
+	var action auth.Action
+	switch info.FullMethod {
+	case control.Control_Control_FullMethodName:
+		action = auth.Ping
+		if msg, ok := req.(*control.Message); ok {
+			switch msg.GetAction() {
+			case control.Message_SHUTDOWN:
+				action = auth.Shutdown
+			case control.Message_ELECT:
+				action = auth.Elect
+			}
+		}
+	case control.Control_Digest_FullMethodName:
+		action = auth.Pull
+	default:
+		return handler(ctx, req)
+	}
+	ctx, err := s.authenticate(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	action, ok := streamActions[info.FullMethod]
+	if !ok {
+		return handler(srv, ss)
+	}
+	ctx, err := s.authenticate(ss.Context(), action)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+}
+
+// identityStream replaces the context of a stream with one carrying the peer identity.
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (i *identityStream) Context() context.Context {
+	return i.ctx
+}
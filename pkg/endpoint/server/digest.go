@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/injector"
+	"github.com/kjbreil/syncer/pkg/merkle"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Digest answers with the digests of the subtrees of the data at the paths of the request, as of
+// the last change set of the history.
+func (s *Server) Digest(_ context.Context, req *control.Digests) (*control.Digests, error) {
+	tree, seq, err := s.tree()
+	if err != nil {
+		s.logger.Error(err.Error())
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &control.Digests{Session: s.session, Seq: seq}
+	for _, d := range req.GetDigests() {
+		resp.Digests = append(resp.Digests, tree.Digest(d.GetPath()))
+	}
+	return resp, nil
+}
+
+// Subtrees sends the entries of the subtrees of the data at the paths of the request.
+func (s *Server) Subtrees(req *control.Digests, srv control.Control_SubtreesServer) error {
+	tree, _, err := s.tree()
+	if err != nil {
+		s.logger.Error(err.Error())
+		return status.Error(codes.Internal, err.Error())
+	}
+	for _, d := range req.GetDigests() {
+		for _, e := range tree.Entries(d.GetPath()) {
+			err = srv.Send(e)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tree returns the hash tree of the data and the sequence number of the last change set of the
+// history it is of, it is built again once a change set is added.
+func (s *Server) tree() (*merkle.Tree, uint64, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	if s.digests == nil || s.digestsSeq != s.history.last {
+		tree, err := s.combined.Tree()
+		if err != nil {
+			return nil, 0, err
+		}
+		s.digests, s.digestsSeq = tree, s.history.last
+	}
+	return s.digests, s.digestsSeq, nil
+}
+
+// repair compares the data with the data of the peer of the link every anti-entropy interval and
+// applies the subtrees that differ like a change set received on the stream of the link.
+func (s *Server) repair(ctx context.Context, client control.ControlClient, stream uint64, position *Position) {
+	interval, ok := s.settings.AntiEntropy()
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		err := s.compare(ctx, client, stream, position)
+		if err != nil && !errors.Is(err, injector.ErrRejected) {
+			s.logger.Error(fmt.Errorf("Server.Link(): %w", err).Error())
+		}
+	}
+}
+
+// compare compares the data with the data of the peer once. Until the peer acknowledged every
+// change set of the data compared the writes it has not received yet would be taken for writes it
+// removed, the data is only compared once it did.
+func (s *Server) compare(ctx context.Context, client control.ControlClient, stream uint64, position *Position) error {
+	tree, seq, err := s.tree()
+	if err != nil {
+		return err
+	}
+	if !position.delivered(seq) {
+		return nil
+	}
+	entries, err := tree.Repair(ctx, client, position.synced)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	return s.apply(stream, position.identity(), &control.ChangeSet{Entries: entries})
+}
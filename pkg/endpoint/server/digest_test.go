@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/combined"
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/endpoint/client"
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+type digestData struct {
+	String string
+	Ints   []int
+	Map    map[string]int
+}
+
+// repair repairs data from the server with the Digest and Subtrees requests and returns the hash
+// of the data repaired.
+func repair(t *testing.T, ctx context.Context, ctl control.ControlClient, c *combined.Combined) []byte {
+	t.Helper()
+	tree, err := c.Tree()
+	if err != nil {
+		t.Fatalf("Tree() error: %v", err)
+	}
+	entries, err := tree.Repair(ctx, ctl, func(string, uint64) bool { return true })
+	if err != nil {
+		t.Fatalf("Repair() error: %v", err)
+	}
+	if _, err = c.AddSetFrom("server", entries); err != nil {
+		t.Fatalf("AddSetFrom() error: %v", err)
+	}
+	tree, err = c.Tree()
+	if err != nil {
+		t.Fatalf("Tree() error: %v", err)
+	}
+	return tree.Hash("")
+}
+
+// startDigestServer starts a server for data on a free port and waits for the data to be recorded.
+func startDigestServer(t *testing.T, ctx context.Context, wg *sync.WaitGroup, data *digestData, errs chan *slog.Record) (*Server, *settings.Settings) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	_ = lis.Close()
+
+	stngs := &settings.Settings{Port: port}
+	s, err := New(ctx, wg, data, combined.Shared{}, nil, stngs, errs)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	for {
+		s.historyMu.Lock()
+		last := s.history.last
+		s.historyMu.Unlock()
+		if last > 0 {
+			return s, stngs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestServer_Digest verifies data missing changes of the server, one of them never recorded in the
+// history, is repaired by pulling the subtrees the digests of the server differ in.
+func TestServer_Digest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+	errs := make(chan *slog.Record, 100)
+	go func() {
+		for range errs {
+		}
+	}()
+
+	data := &digestData{String: "first", Ints: []int{1, 2, 3, 4}, Map: map[string]int{"a": 1, "b": 2}}
+	s, stngs := startDigestServer(t, ctx, &wg, data, errs)
+	conn, err := client.Dial(ctx, net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: stngs.Port}, stngs)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer conn.Close()
+	ctl := control.NewControlClient(conn)
+
+	replica := &digestData{String: "old", Ints: []int{1, 5}, Map: map[string]int{"a": 1, "c": 3}}
+	c, err := combined.New(ctx, replica)
+	if err != nil {
+		t.Fatalf("combined.New() error: %v", err)
+	}
+	if _, err = c.Entries(replica); err != nil {
+		t.Fatalf("Entries() error: %v", err)
+	}
+
+	tree, _, err := s.tree()
+	if err != nil {
+		t.Fatalf("tree() error: %v", err)
+	}
+	if got := repair(t, ctx, ctl, c); !reflect.DeepEqual(got, tree.Hash("")) {
+		t.Fatalf("repaired hash differs from the server")
+	}
+	if !reflect.DeepEqual(replica, data) {
+		t.Fatalf("repaired data = %+v, want %+v", replica, data)
+	}
+
+	// a change lost: the data of the server changes without a change set
+	s.historyMu.Lock()
+	data.String = "second"
+	data.Ints = data.Ints[:2]
+	delete(data.Map, "b")
+	if _, err = s.combined.Entries(data); err != nil {
+		t.Fatalf("Entries() error: %v", err)
+	}
+	s.digests = nil
+	want := *data
+	s.historyMu.Unlock()
+
+	tree, _, err = s.tree()
+	if err != nil {
+		t.Fatalf("tree() error: %v", err)
+	}
+	if got := repair(t, ctx, ctl, c); !reflect.DeepEqual(got, tree.Hash("")) {
+		t.Fatalf("repaired hash differs from the server")
+	}
+	if !reflect.DeepEqual(*replica, want) {
+		t.Fatalf("repaired data = %+v, want %+v", *replica, want)
+	}
+}
+
+// TestServer_CompareUnacknowledged verifies a link does not take the writes the peer did not
+// acknowledge yet for writes the peer removed.
+func TestServer_CompareUnacknowledged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+	errs := make(chan *slog.Record, 100)
+	go func() {
+		for range errs {
+		}
+	}()
+
+	peer, peerSettings := startDigestServer(t, ctx, &wg, &digestData{String: "peer"}, errs)
+	data := &digestData{String: "peer", Map: map[string]int{"local": 1}}
+	s, _ := startDigestServer(t, ctx, &wg, data, errs)
+
+	conn, err := client.Dial(ctx, net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: peerSettings.Port}, peerSettings)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer conn.Close()
+	ctl := control.NewControlClient(conn)
+
+	// every change set of the peer is applied, the local write is not acknowledged yet
+	peer.historyMu.Lock()
+	position := &Position{session: peer.session, seq: peer.history.last}
+	peer.historyMu.Unlock()
+
+	if err = s.compare(ctx, ctl, s.streams.Add(1), position); err != nil {
+		t.Fatalf("compare() error: %v", err)
+	}
+	if data.Map["local"] != 1 {
+		t.Fatal("write not acknowledged by the peer removed")
+	}
+
+	// once acknowledged the peer not having the write means it removed it
+	s.historyMu.Lock()
+	position.ack(s.history.last)
+	s.historyMu.Unlock()
+	if err = s.compare(ctx, ctl, s.streams.Add(1), position); err != nil {
+		t.Fatalf("compare() error: %v", err)
+	}
+	if _, ok := data.Map["local"]; ok {
+		t.Fatal("write removed by the peer not repaired")
+	}
+}
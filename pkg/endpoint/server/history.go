@@ -0,0 +1,106 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/kjbreil/syncer/pkg/control"
+)
+
+// history holds the last change sets extracted from the data by their sequence number, so a
+// client reconnecting receives the change sets it missed instead of the entire data.
+type history struct {
+	size int
+	// last is the sequence number of the last change set, sets holds the change sets up to it
+	last uint64
+	sets []changeSet
+	// held holds the IDs of the change sets of the history
+	held map[setID]struct{}
+}
+
+// changeSet is a change set of the history with the stream it was received from, zero when the
+// server extracted it from the data.
+type changeSet struct {
+	entries control.Entries
+	stream  uint64
+	// origin, clock and route are sent along in a mesh, see control.ChangeSet
+	origin string
+	clock  uint64
+	route  []string
+}
+
+// setID identifies a change set in a mesh, it is only known for change sets stamped by a clock.
+type setID struct {
+	origin string
+	clock  uint64
+}
+
+func newHistory(size int) *history {
+	return &history{size: size, held: make(map[setID]struct{})}
+}
+
+// id returns the ID of the change set and false when it has none.
+func (set changeSet) id() (setID, bool) {
+	return setID{origin: set.origin, clock: set.clock}, set.origin != "" && set.clock > 0
+}
+
+// forwards reports if the change set is sent to the endpoint peer of a mesh: it did not pass
+// through it yet and was forwarded less than hops times.
+func (set changeSet) forwards(peer string, hops int) bool {
+	return set.origin != peer && !slices.Contains(set.route, peer) && len(set.route) <= hops
+}
+
+// changes returns the change set to send.
+func (set changeSet) changes() *control.ChangeSet {
+	return &control.ChangeSet{
+		Entries: set.entries,
+		Origin:  set.origin,
+		Clock:   set.clock,
+		Route:   set.route,
+	}
+}
+
+// add appends the change set and returns its sequence number, the oldest change set is dropped
+// when the history is full.
+func (h *history) add(set changeSet) uint64 {
+	h.last++
+	h.sets = append(h.sets, set)
+	if id, ok := set.id(); ok {
+		h.held[id] = struct{}{}
+	}
+	if len(h.sets) > h.size {
+		for _, dropped := range h.sets[:len(h.sets)-h.size] {
+			if id, ok := dropped.id(); ok {
+				delete(h.held, id)
+			}
+		}
+		h.sets = h.sets[len(h.sets)-h.size:]
+	}
+	return h.last
+}
+
+// holds reports if the history holds the change set with the ID of set, e.g. one that reached
+// the server through another peer of the mesh before.
+func (h *history) holds(set changeSet) bool {
+	id, ok := set.id()
+	if !ok {
+		return false
+	}
+	_, held := h.held[id]
+	return held
+}
+
+// next returns the change set following seq, one without entries when seq is the last one. It
+// returns false when the change set following seq is no longer or not yet held.
+func (h *history) next(seq uint64) (changeSet, bool) {
+	if seq > h.last {
+		return changeSet{}, false
+	}
+	if seq == h.last {
+		return changeSet{}, true
+	}
+	first := h.last - uint64(len(h.sets)) + 1
+	if seq+1 < first {
+		return changeSet{}, false
+	}
+	return h.sets[seq+1-first], true
+}
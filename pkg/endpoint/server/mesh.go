@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"google.golang.org/grpc"
+)
+
+// Position is the last change set of the server of a peer applied by the links to it, the next
+// link resumes after it.
+type Position struct {
+	mu      sync.Mutex
+	session string
+	seq     uint64
+	// acked is the last change set of this server the peer acknowledged on the current link
+	acked uint64
+	// peer is the ID the peer sent in its first frame on the current link
+	peer string
+}
+
+// get returns the session of the server of the peer and the sequence number of its last change
+// set applied.
+func (p *Position) get() (string, uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.session, p.seq
+}
+
+// synced reports if the change set seq of the server session is the last one applied.
+func (p *Position) synced(session string, seq uint64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.session == session && p.seq == seq
+}
+
+// delivered reports if the peer acknowledged every change set of this server up to seq.
+func (p *Position) delivered(seq uint64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.acked >= seq
+}
+
+// ack records the last change set of this server acknowledged by the peer.
+func (p *Position) ack(seq uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.acked = seq
+}
+
+// identify records the ID of the peer sent in its first frame.
+func (p *Position) identify(peer string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peer = peer
+}
+
+// identity returns the ID of the peer, the identity its writes are checked against the access list
+// with.
+func (p *Position) identity() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.peer
+}
+
+// applied moves the position past the frame once its change set is applied, a snapshot starts the
+// session of the server it came from.
+func (p *Position) applied(f *control.Frame) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if f.GetSnapshot() {
+		p.session = f.GetSession()
+	}
+	p.seq = f.GetSeq()
+}
+
+// Link opens a PushPull stream over conn to the server of the peer of a mesh and exchanges change
+// sets with it until the stream fails, ctx is done or the server stops. The link receives the
+// change sets of the peer following position and sends the entire data first, the change sets
+// received are forwarded to the other streams of the server. The subtrees of the data differing
+// from the data of the peer are pulled every anti-entropy interval. The writes of the peer are
+// checked against the access list with the ID the peer sends in its first frame, peer only names
+// the peer in the logs.
+func (s *Server) Link(ctx context.Context, conn grpc.ClientConnInterface, peer string, position *Position) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+	stream, err := control.NewControlClient(conn).PushPull(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrServerLink, peer, err)
+	}
+	id, resume := position.get()
+	err = stream.Send(&control.Frame{Session: id, Resume: resume, Peer: s.id})
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrServerLink, peer, err)
+	}
+
+	s.logger.Info(fmt.Sprintf("Server.Link() to %s started", peer))
+	// the peer acknowledges the change sets sent on this link again
+	position.ack(0)
+	position.identify("")
+	streamID := s.streams.Add(1)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.repair(ctx, control.NewControlClient(conn), streamID, position)
+	}()
+	s.exchange(ctx, cancel, stream, streamID, "", position)
+	s.logger.Info(fmt.Sprintf("Server.Link() to %s stopped", peer))
+	return nil
+}
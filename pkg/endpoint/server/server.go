@@ -2,14 +2,19 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
@@ -19,13 +24,26 @@ import (
 
 	"github.com/kjbreil/syncer/pkg/combined"
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/endpoint/auth"
 	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+	"github.com/kjbreil/syncer/pkg/injector"
+	"github.com/kjbreil/syncer/pkg/merkle"
+	"github.com/kjbreil/syncer/pkg/tags"
 	slogchannel "github.com/samber/slog-channel"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+// Election is the state of the endpoint in the leader election.
+type Election interface {
+	// Leader returns the endpoint as the server.
+	Leader() *control.Leader
+	// StepDown reports if the server steps down for the candidate.
+	StepDown(candidate *control.Leader) bool
+}
+
 type Server struct {
 	control.UnsafeControlServer
 	grpcServer *grpc.Server
@@ -37,6 +55,31 @@ type Server struct {
 	// // server injector not used yet
 	// injector *injector.Injector
 
+	// id is the ID of the endpoint, change sets in a mesh carry the IDs of the endpoints they
+	// passed through
+	id            string
+	election      Election
+	authenticator auth.Authenticator
+	authorize     auth.Authorizer
+	settings      *settings.Settings
+
+	// changed is signalled by Notify to record the changes of the data immediately
+	changed chan struct{}
+	// notify holds a channel per PushPull stream that is signalled when a change set is recorded
+	notify   map[chan struct{}]struct{}
+	notifyMu sync.Mutex
+
+	// session identifies the history, the sequence numbers of another server do not match it
+	session   string
+	history   *history
+	historyMu sync.Mutex
+	// digests is the hash tree of the data as of the change set digestsSeq of the history
+	digests    *merkle.Tree
+	digestsSeq uint64
+	// streams numbers the PushPull streams, the change sets received from a stream are not sent
+	// back to it
+	streams atomic.Uint64
+
 	data   any
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -49,44 +92,92 @@ var (
 	ErrWebServerExited = errors.New("grpc web server exited")
 	ErrServerListen    = errors.New("server could not start listening")
 	ErrServerInjector  = errors.New("server could not create injector")
+	ErrServerTLS       = errors.New("server could not configure tls")
+	ErrServerLink      = errors.New("server could not link to peer")
+	// ErrServerWriteRejected is reported for the writes a peer of a mesh did not accept.
+	ErrServerWriteRejected = errors.New("peer rejected write")
 )
 
-func New(ctx context.Context, wg *sync.WaitGroup, data any, stngs *settings.Settings, errChan chan *slog.Record) (*Server, error) {
+// New starts a server for the data. The election answers the leader election, when nil the server
+// never steps down.
+func New(ctx context.Context, wg *sync.WaitGroup, data any, shared combined.Shared, election Election, stngs *settings.Settings, errChan chan *slog.Record) (*Server, error) {
 	lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", stngs.Port))
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrServerListen, err)
 	}
-	var opts []grpc.ServerOption
-
 	s := &Server{
-		grpcServer: grpc.NewServer(opts...),
-		logger:     slog.New(slogchannel.Option{Level: slog.LevelDebug, Channel: errChan}.NewChannelHandler()),
+		logger: slog.New(slogchannel.Option{Level: slog.LevelDebug, Channel: errChan}.NewChannelHandler()),
 		// extractor:  ext,
-		data: data,
-		mu:   &sync.Mutex{},
-		wg:   wg,
+		data:          data,
+		mu:            &sync.Mutex{},
+		wg:            wg,
+		election:      election,
+		authenticator: stngs.Authenticator,
+		authorize:     stngs.Authorize,
+		settings:      stngs,
+		changed:       make(chan struct{}, 1),
+		notify:        make(map[chan struct{}]struct{}),
+		history:       newHistory(stngs.History()),
+	}
+	if shared.Clock != nil {
+		s.id = shared.Clock.Origin()
 	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	s.session = hex.EncodeToString(b)
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(append([]grpc.UnaryServerInterceptor{s.unaryInterceptor}, stngs.UnaryInterceptors...)...),
+		grpc.ChainStreamInterceptor(append([]grpc.StreamServerInterceptor{s.streamInterceptor}, stngs.StreamInterceptors...)...),
+	}
+	s.grpcServer = grpc.NewServer(opts...)
 	reflection.Register(s.grpcServer)
 
 	s.ctx, s.cancel = context.WithCancel(ctx)
 
 	grpcWebServer := grpcweb.WrapServer(s.grpcServer)
 
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Route standard gRPC requests to the gRPC server
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			s.grpcServer.ServeHTTP(w, r)
+		} else if grpcWebServer.IsGrpcWebRequest(r) {
+			grpcWebServer.ServeHTTP(w, r)
+		}
+	})
+
 	httpServer := &http.Server{
-		Handler: h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Route standard gRPC requests to the gRPC server
-			if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
-				s.grpcServer.ServeHTTP(w, r)
-			} else if grpcWebServer.IsGrpcWebRequest(r) {
-				grpcWebServer.ServeHTTP(w, r)
-			}
-		}), &http2.Server{}),
+		// handshake failures are logged by the http server, send them to the errors channel
+		ErrorLog: slog.NewLogLogger(s.logger.Handler(), slog.LevelError),
+	}
+
+	if stngs.TLS != nil {
+		httpServer.TLSConfig, err = stngs.TLS.ServerConfig()
+		if err != nil {
+			_ = lis.Close()
+			s.cancel()
+			return nil, fmt.Errorf("%w: %w", ErrServerTLS, err)
+		}
+		httpServer.Handler = handler
+	} else {
+		httpServer.Handler = h2c.NewHandler(handler, &http2.Server{})
 	}
 
 	s.combined, err = combined.New(s.ctx, data)
 	if err != nil {
+		_ = lis.Close()
+		s.cancel()
 		return nil, fmt.Errorf("%w: %w", ErrServerInjector, err)
 	}
+	s.combined.SetRole(tags.Server)
+	s.combined.Share(shared)
+	s.combined.SetACL(stngs.ACL)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.recordChanges()
+	}()
 
 	control.RegisterControlServer(s.grpcServer, s)
 	// go func() {
@@ -100,7 +191,12 @@ func New(ctx context.Context, wg *sync.WaitGroup, data any, stngs *settings.Sett
 	// }()
 
 	go func() {
-		err = httpServer.Serve(lis)
+		if httpServer.TLSConfig != nil {
+			// certificates are already part of the TLSConfig
+			_ = httpServer.ServeTLS(lis, "", "")
+		} else {
+			_ = httpServer.Serve(lis)
+		}
 		s.logger.Error(ErrWebServerExited.Error())
 
 		s.cancel()
@@ -110,7 +206,7 @@ func New(ctx context.Context, wg *sync.WaitGroup, data any, stngs *settings.Sett
 	go func() {
 		<-s.ctx.Done()
 		s.grpcServer.Stop()
-		err = httpServer.Shutdown(s.ctx)
+		err := httpServer.Shutdown(s.ctx)
 		if err != nil {
 			s.logger.Error(err.Error())
 		}
@@ -124,6 +220,11 @@ func (s *Server) Running() bool {
 	return s.ctx.Err() == nil
 }
 
+// Stop stops the server.
+func (s *Server) Stop() {
+	s.cancel()
+}
+
 func (s *Server) AddExtHandler(ext func() error) {
 	s.combined.ExtractorChanges(ext)
 }
@@ -132,9 +233,167 @@ func (s *Server) AddInjHandler(inj func() error) {
 	s.combined.InjectorChanges(inj)
 }
 
+// Notify signals the server to extract and send the changes of the data immediately instead of
+// waiting for the next poll.
+func (s *Server) Notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// recordChanges records the changes of the data at every poll and when notified until the server
+// stops.
+func (s *Server) recordChanges() {
+	var poll <-chan time.Time
+	if interval, ok := s.settings.Polling(); ok {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	for {
+		_, err := s.record()
+		if err != nil {
+			s.logger.Error(err.Error())
+		}
+		select {
+		case <-poll:
+		case <-s.changed:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// record adds the changes of the data to the history and signals the streams, it returns the
+// recorded entries.
+func (s *Server) record() (control.Entries, error) {
+	s.historyMu.Lock()
+	entries, err := s.combined.Entries(s.data)
+	if len(entries) > 0 {
+		s.history.add(changeSet{entries: entries, origin: s.id, clock: entries[0].GetClock()})
+	}
+	s.historyMu.Unlock()
+	if len(entries) > 0 {
+		s.broadcast()
+	}
+	return entries, err
+}
+
+// apply adds the change set received from the stream to the data and to the history, the other
+// streams forward the entries applied to their clients. A change set that passed through the
+// server before, e.g. through another peer of a mesh, is dropped.
+func (s *Server) apply(stream uint64, identity string, changes *control.ChangeSet) error {
+	set := changeSet{stream: stream, origin: changes.GetOrigin(), clock: changes.GetClock(), route: changes.GetRoute()}
+	if s.id != "" {
+		if set.origin == s.id || slices.Contains(set.route, s.id) {
+			return nil
+		}
+		set.route = append(slices.Clone(set.route), s.id)
+	}
+	s.historyMu.Lock()
+	if s.history.holds(set) {
+		s.historyMu.Unlock()
+		return nil
+	}
+	applied, err := s.combined.AddSetFrom(identity, changes.GetEntries())
+	if len(applied) > 0 {
+		set.entries = applied
+		s.history.add(set)
+	}
+	s.historyMu.Unlock()
+	if len(applied) > 0 {
+		s.broadcast()
+	}
+	return err
+}
+
+// next returns the frame for the stream following seq or nil when there is none yet. When full is
+// set or the history no longer holds the change set following seq the frame holds a snapshot. A
+// stream to the endpoint peer of a mesh only receives the change sets forwarded to it, a client
+// does not receive the change sets of its origin.
+func (s *Server) next(seq uint64, full bool, stream uint64, origin, peer string) (*control.Frame, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	if !full {
+		set, ok := s.history.next(seq)
+		if ok {
+			if set.entries == nil {
+				return nil, nil
+			}
+			f := &control.Frame{Seq: seq + 1}
+			// the client sent the change set itself, on this stream or before reconnecting, it
+			// only moves past it
+			sent := set.stream == stream || origin != "" && set.origin == origin
+			if !sent && (peer == "" || set.forwards(peer, s.settings.Hops())) {
+				f.Changes = set.changes()
+			}
+			return f, nil
+		}
+	}
+	return s.snapshot()
+}
+
+// snapshot returns a frame holding the entire data as of the last change set of the history, nil
+// when nothing was recorded yet. The history lock must be held.
+func (s *Server) snapshot() (*control.Frame, error) {
+	if s.history.last == 0 {
+		return nil, nil
+	}
+	entries, err := s.combined.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &control.Frame{
+		Seq:      s.history.last,
+		Changes:  &control.ChangeSet{Entries: entries, Origin: s.id},
+		Session:  s.session,
+		Snapshot: true,
+	}, nil
+}
+
+// broadcast signals every connected stream that a change set was recorded.
+func (s *Server) broadcast() {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+	for ch := range s.notify {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel signalled when a change set is recorded and a function to stop the
+// signals.
+func (s *Server) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	s.notifyMu.Lock()
+	s.notify[ch] = struct{}{}
+	s.notifyMu.Unlock()
+	return ch, func() {
+		s.notifyMu.Lock()
+		delete(s.notify, ch)
+		s.notifyMu.Unlock()
+	}
+}
+
 func (s *Server) Control(_ context.Context, message *control.Message) (*control.Response, error) {
 	switch message.GetAction() {
 	case control.Message_PING:
+		return &control.Response{
+			Type:   control.Response_OK,
+			Leader: s.leader(),
+		}, nil
+	case control.Message_ELECT:
+		if s.election == nil || !s.election.StepDown(message.GetCandidate()) {
+			return &control.Response{
+				Type:   control.Response_ERROR,
+				Leader: s.leader(),
+			}, nil
+		}
+		s.logger.Info(fmt.Sprintf("server stepping down for %s", message.GetCandidate().GetID()))
+		s.cancel()
 		return &control.Response{
 			Type: control.Response_OK,
 		}, nil
@@ -150,24 +409,66 @@ func (s *Server) Control(_ context.Context, message *control.Message) (*control.
 	}
 }
 
+// leader returns the server in the leader election, nil without an election.
+func (s *Server) leader() *control.Leader {
+	if s.election == nil {
+		return nil
+	}
+	return s.election.Leader()
+}
+
 func (s *Server) Pull(req *control.Request, srv control.Control_PullServer) error {
+	session := req.GetSession()
 	switch req.GetType() {
 	case control.Request_INIT:
-		s.combined.Reset()
-		fallthrough
+		// the entire data is sent
+		session = ""
 	case control.Request_CHANGES:
-		entries, _ := s.combined.Entries(s.data)
-		for _, e := range entries {
-			err := srv.Send(e)
-			if err != nil {
-				s.logger.Error(err.Error())
-			}
+	default:
+		return nil
+	}
+
+	_, err := s.record()
+	if err != nil {
+		s.logger.Error(err.Error())
+	}
+	entries, last, err := s.since(session, req.GetResume())
+	if err != nil {
+		s.logger.Error(err.Error())
+	}
+	srv.SetTrailer(metadata.Pairs(control.SessionKey, s.session, control.SeqKey, strconv.FormatUint(last, 10)))
+	for _, e := range entries {
+		err = srv.Send(e)
+		if err != nil {
+			s.logger.Error(err.Error())
 		}
 	}
 
 	return nil
 }
 
+// since returns the entries of the change sets following seq and the sequence number of the last
+// of them. When session is not the session of the server or the history no longer holds them the
+// entries are a snapshot.
+func (s *Server) since(session string, seq uint64) (control.Entries, uint64, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	var entries control.Entries
+	for session == s.session {
+		set, ok := s.history.next(seq)
+		if !ok {
+			break
+		}
+		if set.entries == nil {
+			return entries, seq, nil
+		}
+		entries = append(entries, set.entries...)
+		seq++
+	}
+	f, err := s.snapshot()
+	return f.GetChanges().GetEntries(), s.history.last, err
+}
+
 func (s *Server) Push(server control.Control_PushServer) error {
 	mu := &sync.Mutex{}
 
@@ -190,10 +491,14 @@ func (s *Server) Push(server control.Control_PushServer) error {
 		s.logger.Error(fmt.Errorf("Server.PushPull(): %w", err).Error())
 		return err
 	}
+	identity, _ := auth.FromContext(server.Context())
 	mu.Lock()
-	err = s.combined.Add(e)
-	_, _ = s.combined.Entries(s.data)
+	err = s.apply(0, identity, &control.ChangeSet{Entries: control.Entries{e}})
 	mu.Unlock()
+	if errors.Is(err, injector.ErrRejected) {
+		s.logger.Warn(fmt.Errorf("Server.Push(): %w", err).Error())
+		return server.SendAndClose(&control.Response{Type: control.Response_ERROR})
+	}
 	if err != nil {
 		s.logger.Error(fmt.Errorf("Server.PushPull(): %w", err).Error())
 		return err
@@ -203,36 +508,94 @@ func (s *Server) Push(server control.Control_PushServer) error {
 
 func (s *Server) PushPull(server control.Control_PushPullServer) error {
 	ctx, cancel := context.WithCancel(s.ctx)
+	identity, _ := auth.FromContext(server.Context())
 
+	s.logger.Info("Server.PushPull() started")
+	s.exchange(ctx, cancel, server, s.streams.Add(1), identity, nil)
+	s.logger.Info("Server.PushPull() stopped")
+
+	return nil
+}
+
+// frames is either side of a PushPull stream.
+type frames interface {
+	Send(*control.Frame) error
+	Recv() (*control.Frame, error)
+}
+
+// exchange sends the change sets of the history on the stream numbered id and applies the change
+// sets received from it until cancel is called, the stream fails or ctx is done. The first frame
+// received names the change set to continue with. Position is nil for the streams opened by
+// clients, for a link to a peer of a mesh it follows the change sets of the peer applied and the
+// ID the peer sends in its first frame replaces identity.
+func (s *Server) exchange(ctx context.Context, cancel context.CancelFunc, stream frames, id uint64, identity string, position *Position) {
 	var wg sync.WaitGroup
 	mu := &sync.Mutex{}
-	checkInterval := time.Second
+
+	recorded, unsubscribe := s.subscribe()
+	defer unsubscribe()
+
+	// acked is the last frame acknowledged by the peer, acks is signalled when it changes
+	var acked atomic.Uint64
+	acks := make(chan struct{}, 1)
+	// hello receives the first frame of the peer naming the change set it resumes after
+	hello := make(chan *control.Frame, 1)
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		defer cancel()
+		var first *control.Frame
+		select {
+		case first = <-hello:
+		case <-ctx.Done():
+			return
+		}
+		seq := first.GetResume()
+		// a peer that never connected to this server receives the entire data
+		full := first.GetSession() != s.session
+		origin, peer := first.GetOrigin(), first.GetPeer()
+		if peer != "" && position == nil {
+			// the peer of the mesh learns the ID of this endpoint
+			mu.Lock()
+			err := stream.Send(&control.Frame{Peer: s.id})
+			mu.Unlock()
+			if err != nil {
+				s.logger.Error(err.Error())
+				return
+			}
+		}
 		for {
-			select {
-			case <-time.After(checkInterval):
-				mu.Lock()
-				entries, err := s.combined.Entries(s.data)
-				if err != nil {
-					s.logger.Error(err.Error())
-					mu.Unlock()
-				}
-				for _, e := range entries {
-					err = server.Send(e)
-					if err != nil {
-						s.logger.Error(err.Error())
-						mu.Unlock()
-						return
-					}
+			f, err := s.next(seq, full, id, origin, peer)
+			if err != nil {
+				s.logger.Error(err.Error())
+				return
+			}
+			full = false
+			if f == nil {
+				select {
+				case <-recorded:
+				case <-ctx.Done():
+					return
 				}
-				mu.Unlock()
-			case <-ctx.Done():
+				continue
+			}
+			mu.Lock()
+			err = stream.Send(f)
+			mu.Unlock()
+			if err != nil {
+				s.logger.Error(err.Error())
 				return
 			}
+			// the change set is sent again on the next connection until the peer applied it
+			for acked.Load() < f.GetSeq() {
+				select {
+				case <-acks:
+				case <-ctx.Done():
+					return
+				}
+			}
+			seq = f.GetSeq()
 		}
 	}()
 
@@ -240,8 +603,9 @@ func (s *Server) PushPull(server control.Control_PushPullServer) error {
 	go func() {
 		defer wg.Done()
 		defer cancel()
+		started := false
 		for {
-			e, err := server.Recv()
+			f, err := stream.Recv()
 			if errors.Is(err, io.EOF) {
 				return
 			}
@@ -260,20 +624,87 @@ func (s *Server) PushPull(server control.Control_PushPullServer) error {
 				s.logger.Error(fmt.Errorf("Server.PushPull(): %w", err).Error())
 				return
 			}
+			if !started {
+				started = true
+				if position != nil {
+					identity = f.GetPeer()
+					position.identify(identity)
+				}
+				hello <- f
+			}
+			if f.GetAck() > 0 {
+				for _, e := range f.GetRejected() {
+					s.logger.Error(fmt.Errorf("%w: %s: %s", ErrServerWriteRejected, e.Path(), e.GetReason()).Error())
+				}
+				acked.Store(f.GetAck())
+				if position != nil {
+					position.ack(f.GetAck())
+				}
+				select {
+				case acks <- struct{}{}:
+				default:
+				}
+			}
+			if f.GetSeq() == 0 {
+				continue
+			}
+
 			mu.Lock()
-			err = s.combined.Add(e)
-			_, _ = s.combined.Entries(s.data)
+			entries := f.GetChanges().GetEntries()
+			err = s.apply(id, identity, f.GetChanges())
+			if errors.Is(err, injector.ErrRejected) {
+				// tell the sender which entries were not applied in the acknowledgement
+				s.logger.Warn(fmt.Errorf("Server.PushPull(): %w", err).Error())
+				err = nil
+			}
+			if err == nil {
+				if position != nil {
+					position.applied(f)
+				}
+				var rejects []*control.Entry
+				for _, e := range entries {
+					if e.GetRejected() {
+						rejects = append(rejects, s.rejected(e)...)
+					}
+				}
+				err = stream.Send(&control.Frame{Ack: f.GetSeq(), Rejected: rejects})
+			}
 			mu.Unlock()
 			if err != nil {
+				// none of the entries were applied and the frame is not acknowledged, the peer
+				// sends it again after reconnecting
 				s.logger.Error(fmt.Errorf("Server.PushPull(): %w", err).Error())
 				return
 			}
 		}
 	}()
 
-	s.logger.Info("Server.PushPull() started")
 	wg.Wait()
-	s.logger.Info("Server.PushPull() stopped")
+}
 
-	return nil
+// rejected returns the entries to send back to the sender of the entry rejected by the injector,
+// they set its path back to the data of the server. Without a value to set it back to only the
+// path is sent.
+func (s *Server) rejected(e *control.Entry) control.Entries {
+	var restore control.Entries
+	tree, _, err := s.tree()
+	if err != nil {
+		s.logger.Error(err.Error())
+	} else {
+		restore = tree.Restore(e)
+	}
+	if len(restore) == 0 {
+		return control.Entries{{Key: e.GetKey(), Rejected: true, Reason: e.GetReason()}}
+	}
+	rejects := make(control.Entries, 0, len(restore))
+	for _, r := range restore {
+		rejects = append(rejects, &control.Entry{
+			Key:      r.GetKey(),
+			Value:    r.GetValue(),
+			Remove:   r.GetRemove(),
+			Rejected: true,
+			Reason:   e.GetReason(),
+		})
+	}
+	return rejects
 }
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/acl"
+	"github.com/kjbreil/syncer/pkg/combined"
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/endpoint/client"
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// TestServer_NoEchoAfterReconnect verifies a client resuming from before its own change set on a
+// new stream does not receive it back.
+func TestServer_NoEchoAfterReconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+	errs := make(chan *slog.Record, 100)
+	go func() {
+		for range errs {
+		}
+	}()
+
+	data := &digestData{String: "server"}
+	s, stngs := startDigestServer(t, ctx, &wg, data, errs)
+	conn, err := client.Dial(ctx, net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: stngs.Port}, stngs)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer conn.Close()
+	ctl := control.NewControlClient(conn)
+
+	s.historyMu.Lock()
+	resume := s.history.last
+	s.historyMu.Unlock()
+	hello := &control.Frame{Session: s.session, Resume: resume, Origin: "client"}
+
+	// the client sends a change set and disconnects once it is applied
+	streamCtx, streamCancel := context.WithCancel(ctx)
+	stream, err := ctl.PushPull(streamCtx)
+	if err != nil {
+		t.Fatalf("PushPull() error: %v", err)
+	}
+	entry := control.NewEntry(2, "client")
+	entry.Key = []*control.Key{{Key: "digestData"}, {Key: "String"}}
+	changes := &control.ChangeSet{Entries: control.Entries{entry}, Origin: "client"}
+	for _, f := range []*control.Frame{hello, {Seq: 1, Changes: changes}} {
+		if err = stream.Send(f); err != nil {
+			t.Fatalf("Send() error: %v", err)
+		}
+	}
+	for {
+		f, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error: %v", err)
+		}
+		if f.GetAck() == 1 {
+			break
+		}
+	}
+	streamCancel()
+
+	// the new stream resumes from before the change set of the client
+	stream, err = ctl.PushPull(ctx)
+	if err != nil {
+		t.Fatalf("PushPull() error: %v", err)
+	}
+	if err = stream.Send(hello); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	f, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error: %v", err)
+	}
+	if f.GetSeq() != resume+1 {
+		t.Fatalf("Seq = %d, want %d", f.GetSeq(), resume+1)
+	}
+	if len(f.GetChanges().GetEntries()) != 0 {
+		t.Fatalf("change set of the client sent back: %v", f.GetChanges().GetEntries())
+	}
+}
+
+// TestNew_ReleasesListener verifies a server failing to start does not keep listening on its port.
+func TestNew_ReleasesListener(t *testing.T) {
+	port := freePort(t)
+	var wg sync.WaitGroup
+	_, err := New(context.Background(), &wg, nil, combined.Shared{}, nil, &settings.Settings{Port: port}, make(chan *slog.Record, 10))
+	if !errors.Is(err, ErrServerInjector) {
+		t.Fatalf("New() error = %v, want %v", err, ErrServerInjector)
+	}
+	lis, err := net.Listen("tcp", net.JoinHostPort("0.0.0.0", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("port still in use: %v", err)
+	}
+	_ = lis.Close()
+}
+
+// TestServer_LinkIdentity verifies the writes received on a link are checked against the access
+// list with the ID of the peer, not its address.
+func TestServer_LinkIdentity(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+	errs := make(chan *slog.Record, 100)
+	go func() {
+		for range errs {
+		}
+	}()
+
+	rules, err := acl.New(
+		acl.Rule{Path: "String", Allow: false},
+		acl.Rule{Path: "String", Peers: []string{"remote"}, Allow: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu := &sync.RWMutex{}
+	data := &digestData{String: "local"}
+	local, err := New(ctx, &wg, data, combined.Shared{Mutex: mu, Clock: combined.NewClock("local")}, nil, &settings.Settings{Port: freePort(t), ACL: rules}, errs)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	remoteSettings := &settings.Settings{Port: freePort(t)}
+	_, err = New(ctx, &wg, &digestData{String: "remote"}, combined.Shared{Clock: combined.NewClock("remote")}, nil, remoteSettings, errs)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	peer := net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: remoteSettings.Port}
+	conn, err := client.Dial(ctx, peer, remoteSettings)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer conn.Close()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = local.Link(ctx, conn, peer.String(), &Position{})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.RLock()
+		got := data.String
+		mu.RUnlock()
+		if got == "remote" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("String = %q, write of the peer not accepted", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// freePort returns a port nothing listens on.
+func freePort(t *testing.T) int {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
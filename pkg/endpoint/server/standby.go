@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+	slogchannel "github.com/samber/slog-channel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Standby answers the leader election on the port of the server while the endpoint is not the
+// server, so the endpoints ranked lower find it and do not become the server themselves. It only
+// answers PING and ELECT, with the endpoint marked as Standby.
+type Standby struct {
+	control.UnimplementedControlServer
+	server     *Server
+	grpcServer *grpc.Server
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewStandby listens on the port of the server until Stop is called.
+func NewStandby(ctx context.Context, wg *sync.WaitGroup, election Election, stngs *settings.Settings, errChan chan *slog.Record) (*Standby, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", stngs.Port))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrServerListen, err)
+	}
+	// the standby authenticates peers like the server
+	s := &Server{
+		logger:        slog.New(slogchannel.Option{Level: slog.LevelDebug, Channel: errChan}.NewChannelHandler()),
+		election:      election,
+		authenticator: stngs.Authenticator,
+		authorize:     stngs.Authorize,
+		settings:      stngs,
+	}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(append([]grpc.UnaryServerInterceptor{s.unaryInterceptor}, stngs.UnaryInterceptors...)...),
+	}
+	if stngs.TLS != nil {
+		cfg, err := stngs.TLS.ServerConfig()
+		if err != nil {
+			_ = lis.Close()
+			return nil, fmt.Errorf("%w: %w", ErrServerTLS, err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+
+	st := &Standby{
+		server:     s,
+		grpcServer: grpc.NewServer(opts...),
+	}
+	st.ctx, st.cancel = context.WithCancel(ctx)
+	control.RegisterControlServer(st.grpcServer, st)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = st.grpcServer.Serve(lis)
+		st.cancel()
+	}()
+	go func() {
+		<-st.ctx.Done()
+		st.grpcServer.Stop()
+	}()
+
+	return st, nil
+}
+
+// Running reports if the standby still listens.
+func (st *Standby) Running() bool {
+	return st.ctx.Err() == nil
+}
+
+// Stop stops listening, the port is free for the server once Stop returns.
+func (st *Standby) Stop() {
+	st.cancel()
+	st.grpcServer.Stop()
+}
+
+// Control answers PING and ELECT with the endpoint as a standby, there is no server to step down.
+func (st *Standby) Control(_ context.Context, message *control.Message) (*control.Response, error) {
+	leader := st.server.election.Leader()
+	leader.Standby = true
+	if message.GetAction() == control.Message_PING {
+		return &control.Response{Type: control.Response_OK, Leader: leader}, nil
+	}
+	return &control.Response{Type: control.Response_ERROR, Leader: leader}, nil
+}
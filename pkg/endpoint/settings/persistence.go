@@ -0,0 +1,34 @@
+package settings
+
+import "time"
+
+// DefaultSnapshotInterval is used when Persistence.SnapshotInterval is not set.
+const DefaultSnapshotInterval = time.Minute
+
+// Persistence configures saving the data to disk so it survives a restart of every endpoint.
+type Persistence struct {
+	// SnapshotFile is the file the data is saved to. It is loaded when the endpoint is created,
+	// before connecting to peers.
+	SnapshotFile string `json:"snapshot_file"`
+	// SnapshotInterval is how often the snapshot is written while running, it is always written
+	// when the endpoint stops. Zero uses DefaultSnapshotInterval, a negative interval only
+	// writes the snapshot on stop.
+	SnapshotInterval time.Duration `json:"snapshot_interval"`
+	// LogFile is the append-only log every change is written to as it is made or received. It is
+	// replayed on top of the snapshot when the endpoint is created and trimmed once the changes
+	// are saved in a snapshot.
+	LogFile string `json:"log_file"`
+}
+
+// Snapshots returns the interval snapshots are written at and false when they are only written on
+// stop.
+func (p *Persistence) Snapshots() (time.Duration, bool) {
+	switch {
+	case p.SnapshotInterval < 0:
+		return 0, false
+	case p.SnapshotInterval == 0:
+		return DefaultSnapshotInterval, true
+	default:
+		return p.SnapshotInterval, true
+	}
+}
@@ -1,6 +1,32 @@
 package settings
 
-import "net"
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/acl"
+	"github.com/kjbreil/syncer/pkg/combined"
+	"github.com/kjbreil/syncer/pkg/endpoint/auth"
+	"github.com/kjbreil/syncer/pkg/endpoint/discovery"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// DefaultPollInterval is used when PollInterval is not set.
+const DefaultPollInterval = time.Second
+
+// DefaultHistorySize is used when HistorySize is not set.
+const DefaultHistorySize = 1024
+
+// DefaultElectionInterval is used when ElectionInterval is not set.
+const DefaultElectionInterval = 5 * time.Second
+
+// DefaultAntiEntropyInterval is used when AntiEntropyInterval is not set.
+const DefaultAntiEntropyInterval = 30 * time.Second
+
+// DefaultMaxHops is used when MaxHops is not set.
+const DefaultMaxHops = 16
 
 // Settings contains the configuration for the server.
 type Settings struct {
@@ -8,6 +34,131 @@ type Settings struct {
 	Port int `json:"port"`
 	// Peers is a list of peers the server connects to.
 	Peers []net.TCPAddr `json:"peers"`
+	// Hosts are peers given by name, either host:port or the name of a DNS SRV record like
+	// _syncer._tcp.example.com. A name is resolved when the endpoint first tries its peers and again
+	// after an address it resolved to failed, the addresses are added to the peers.
+	Hosts []string `json:"hosts"`
+	// HostResolver looks up the addresses of Hosts, net.DefaultResolver when nil.
+	HostResolver HostResolver `json:"-"`
+	// Discovery announces the endpoint every ElectionInterval and adds the endpoints found with
+	// the same type of data to the peers, e.g. a discovery.Multicast. When nil only Peers are
+	// used. A transport that is an io.Closer is closed when the endpoint stops.
+	Discovery discovery.Transport `json:"-"`
 	// AutoUpdate determines if the server should update itself automatically.
 	AutoUpdate bool `json:"auto_update"`
+	// PollInterval is how often the data is checked for changes in addition to the changes
+	// signalled with Endpoint.Notify. Zero uses DefaultPollInterval, a negative interval
+	// disables polling so only notified changes are sent.
+	PollInterval time.Duration `json:"poll_interval"`
+	// HistorySize is how many change sets the server keeps for clients reconnecting, a client
+	// that missed more receives the entire data. Zero uses DefaultHistorySize.
+	HistorySize int `json:"history_size"`
+	// AntiEntropyInterval is how often a client, or a link of a mesh, compares the hashes of the
+	// subtrees of its data with the server and pulls the subtrees that differ, e.g. after a change
+	// was lost. Zero uses DefaultAntiEntropyInterval, a negative interval disables the comparison.
+	AntiEntropyInterval time.Duration `json:"anti_entropy_interval"`
+	// TLS secures the gRPC and grpc-web listeners and the client connections. When nil
+	// connections are made in plaintext.
+	TLS *TLS `json:"tls"`
+	// Authenticator verifies peers connecting to the server, when nil every peer is accepted.
+	Authenticator auth.Authenticator `json:"-"`
+	// Authorize decides which actions a peer may perform on the server, when nil every
+	// authenticated peer may perform every action.
+	Authorize auth.Authorizer `json:"-"`
+	// UnaryInterceptors and StreamInterceptors are run by the server after the peer is authenticated,
+	// the identity of the peer is available with auth.FromContext.
+	UnaryInterceptors  []grpc.UnaryServerInterceptor  `json:"-"`
+	StreamInterceptors []grpc.StreamServerInterceptor `json:"-"`
+	// Credentials are sent by the client with every request to authenticate with the server.
+	Credentials credentials.PerRPCCredentials `json:"-"`
+	// ACL decides per path and peer identity which writes from clients the server accepts.
+	ACL *acl.ACL `json:"-"`
+	// Persistence saves the data to disk, when nil nothing is saved.
+	Persistence *Persistence `json:"persistence"`
+	// ID identifies the endpoint as the origin of its writes, a random ID is used when empty.
+	// Every endpoint must have a different ID.
+	ID string `json:"id"`
+	// Priority ranks the endpoint in the leader election, of the endpoints reachable the one with
+	// the highest Priority, then the highest ID, is the server.
+	Priority int64 `json:"priority"`
+	// ElectionInterval is how often an endpoint without a server looks for one and how often the
+	// server looks for other servers to settle which one stays. In a mesh it is how often a lost
+	// link to a peer is opened again. Zero uses DefaultElectionInterval.
+	ElectionInterval time.Duration `json:"election_interval"`
+	// Mesh runs the endpoint as a server that also links to every peer instead of electing a
+	// single server, change sets are forwarded from peer to peer.
+	Mesh bool `json:"mesh"`
+	// MaxHops is how many times a change set is forwarded in a mesh at most. Zero uses
+	// DefaultMaxHops.
+	MaxHops int `json:"max_hops"`
+	// ConflictPolicy decides which write is kept when a peer writes a path without knowing about
+	// a local write to it, combined.LastWriterWins when empty. It names a built-in policy or one
+	// of the Resolvers, fields pick another with a tag like `syncer:"conflict=server-wins"`.
+	ConflictPolicy combined.ConflictPolicy `json:"conflict_policy"`
+	// Resolvers are custom conflict resolvers by the name used in ConflictPolicy and tags.
+	Resolvers map[combined.ConflictPolicy]combined.ConflictResolver `json:"-"`
+}
+
+// HostResolver looks up the addresses of host names and DNS SRV records, *net.Resolver implements
+// it.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Lookup returns the resolver of Hosts.
+func (s *Settings) Lookup() HostResolver {
+	if s.HostResolver == nil {
+		return net.DefaultResolver
+	}
+	return s.HostResolver
+}
+
+// Polling returns the interval the data is polled at and false when polling is disabled.
+func (s *Settings) Polling() (time.Duration, bool) {
+	switch {
+	case s.PollInterval < 0:
+		return 0, false
+	case s.PollInterval == 0:
+		return DefaultPollInterval, true
+	default:
+		return s.PollInterval, true
+	}
+}
+
+// AntiEntropy returns the interval the data is compared with the server at and false when the
+// comparison is disabled.
+func (s *Settings) AntiEntropy() (time.Duration, bool) {
+	switch {
+	case s.AntiEntropyInterval < 0:
+		return 0, false
+	case s.AntiEntropyInterval == 0:
+		return DefaultAntiEntropyInterval, true
+	default:
+		return s.AntiEntropyInterval, true
+	}
+}
+
+// History returns the number of change sets the server keeps.
+func (s *Settings) History() int {
+	if s.HistorySize <= 0 {
+		return DefaultHistorySize
+	}
+	return s.HistorySize
+}
+
+// Election returns the interval of the leader election.
+func (s *Settings) Election() time.Duration {
+	if s.ElectionInterval <= 0 {
+		return DefaultElectionInterval
+	}
+	return s.ElectionInterval
+}
+
+// Hops returns how many times a change set is forwarded in a mesh at most.
+func (s *Settings) Hops() int {
+	if s.MaxHops <= 0 {
+		return DefaultMaxHops
+	}
+	return s.MaxHops
 }
@@ -0,0 +1,106 @@
+package settings
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrTLSCertificate = errors.New("could not load tls certificate")
+	ErrTLSCA          = errors.New("could not load tls certificate authority")
+)
+
+// TLS contains the certificate material used to secure the connections between endpoints.
+// The material can be given as files, as a tls.Config or as a mix of both, files are loaded
+// on top of a clone of Config.
+type TLS struct {
+	// CertFile is the PEM encoded certificate presented to peers.
+	CertFile string `json:"cert_file"`
+	// KeyFile is the PEM encoded private key for CertFile.
+	KeyFile string `json:"key_file"`
+	// CAFile is the PEM encoded certificate authority used to verify peers. When set on a
+	// server, clients are required to present a certificate signed by the authority.
+	CAFile string `json:"ca_file"`
+	// ServerName overrides the name the client verifies the server certificate against.
+	ServerName string `json:"server_name"`
+	// Config is used as the base configuration when set.
+	Config *tls.Config `json:"-"`
+}
+
+// ServerConfig returns the tls.Config used by the server listeners.
+func (t *TLS) ServerConfig() (*tls.Config, error) {
+	cfg, err := t.base()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Certificates) == 0 && cfg.GetCertificate == nil {
+		return nil, fmt.Errorf("%w: server requires a certificate", ErrTLSCertificate)
+	}
+
+	if t.CAFile != "" {
+		cfg.ClientCAs, err = loadCA(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	cfg.NextProtos = []string{"h2", "http/1.1"}
+
+	return cfg, nil
+}
+
+// ClientConfig returns the tls.Config used when dialing a server.
+func (t *TLS) ClientConfig() (*tls.Config, error) {
+	cfg, err := t.base()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.CAFile != "" {
+		cfg.RootCAs, err = loadCA(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if t.ServerName != "" {
+		cfg.ServerName = t.ServerName
+	}
+
+	return cfg, nil
+}
+
+// base clones Config and loads the key pair from the files.
+func (t *TLS) base() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.Config != nil {
+		cfg = t.Config.Clone()
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrTLSCertificate, err)
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+
+	return cfg, nil
+}
+
+func loadCA(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTLSCA, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w: no certificates found in %s", ErrTLSCA, file)
+	}
+	return pool, nil
+}
@@ -0,0 +1,69 @@
+package endpoint
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// TestSubscribe verifies subscriptions are called with the old and new value and the origin of
+// changes received from the server.
+func TestSubscribe(t *testing.T) {
+	port := findFreePort(t)
+
+	serverData := &syncStruct{Sub: subStruct{Name: "before"}}
+	serverEP, err := New(serverData, &settings.Settings{Port: port, AutoUpdate: true, PollInterval: -1})
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP)
+	time.Sleep(500 * time.Millisecond)
+
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:         port + 1,
+		Peers:        []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate:   true,
+		PollInterval: -1,
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	changes := make(chan Change, 10)
+	err = clientEP.Subscribe("Sub.*", func(ch Change) {
+		changes <- ch
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	if clientEP.Subscribe("Sub[", func(Change) {}) == nil {
+		t.Fatal("Subscribe() accepted an invalid path")
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP)
+
+	origin := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	want := []Change{
+		{Path: "Sub.Name", Old: "", New: "before", Origin: origin},
+		{Path: "Sub.Name", Old: "before", New: "after", Origin: origin},
+	}
+	for i, w := range want {
+		if i == 1 {
+			serverEP.Update(func() { serverData.Sub.Name = "after" })
+		}
+		select {
+		case got := <-changes:
+			if got != w {
+				t.Fatalf("change %d = %+v, want %+v", i, got, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("change %d not received", i)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package endpoint
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+// lockedBuffer is a bytes.Buffer safe for use as a log destination from several goroutines.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T, dir, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, name+".pem")
+	writePEM(t, file, "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// issue creates a certificate signed by the CA valid for 127.0.0.1 and returns the cert and key files.
+func (ca *testCA) issue(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, file, typ string, der []byte) {
+	t.Helper()
+	err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startTLSServer(t *testing.T, data any, stngs *settings.Settings) (*Endpoint, *lockedBuffer) {
+	t.Helper()
+	ep, err := New(data, stngs)
+	if err != nil {
+		t.Fatalf("server New() error: %v", err)
+	}
+	logs := &lockedBuffer{}
+	ep.SetLogger(slog.NewTextHandler(logs, nil))
+	ep.Run(false)
+	waitForServer(t, ep)
+	time.Sleep(500 * time.Millisecond)
+	return ep, logs
+}
+
+// TestTLS_MutualSync verifies data is synchronized when both sides present certificates signed by the CA.
+func TestTLS_MutualSync(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	serverCert, serverKey := ca.issue(t, dir, "server")
+	clientCert, clientKey := ca.issue(t, dir, "client")
+	port := findFreePort(t)
+
+	serverData := &syncStruct{String: "secure", Int: 7}
+	serverEP, _ := startTLSServer(t, serverData, &settings.Settings{
+		Port:       port,
+		AutoUpdate: true,
+		TLS:        &settings.TLS{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file},
+	})
+	defer serverEP.Stop()
+
+	clientData := &syncStruct{}
+	clientEP, err := New(clientData, &settings.Settings{
+		Port:       port + 1,
+		Peers:      []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate: true,
+		TLS:        &settings.TLS{CertFile: clientCert, KeyFile: clientKey, CAFile: ca.file},
+	})
+	if err != nil {
+		t.Fatalf("client New() error: %v", err)
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP)
+	time.Sleep(time.Second)
+
+	if !readLocked(clientEP, func() bool { return clientData.String == "secure" && clientData.Int == 7 }) {
+		t.Fatal("client data not synced over tls")
+	}
+}
+
+// TestTLS_RejectsUnverifiedPeers verifies clients without a valid certificate are rejected and that
+// both sides report the failure through their errors channel.
+func TestTLS_RejectsUnverifiedPeers(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	otherCA := newTestCA(t, dir, "other")
+	serverCert, serverKey := ca.issue(t, dir, "server")
+	port := findFreePort(t)
+
+	serverEP, serverLogs := startTLSServer(t, &syncStruct{String: "secure"}, &settings.Settings{
+		Port: port,
+		TLS:  &settings.TLS{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file},
+	})
+	defer serverEP.Stop()
+
+	tests := []struct {
+		name    string
+		tls     *settings.TLS
+		logs    func(client, server string) bool
+		problem string
+	}{
+		{
+			name: "no client certificate",
+			tls:  &settings.TLS{CAFile: ca.file},
+			logs: func(_, server string) bool {
+				return strings.Contains(server, "TLS handshake error")
+			},
+			problem: "server did not report the handshake failure",
+		},
+		{
+			name: "untrusted server",
+			tls:  &settings.TLS{CAFile: otherCA.file},
+			logs: func(client, _ string) bool {
+				return strings.Contains(client, "client tls handshake failed")
+			},
+			problem: "client did not report the handshake failure",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientData := &syncStruct{}
+			clientEP, err := New(clientData, &settings.Settings{
+				Port:  port + 1 + i,
+				Peers: []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+				TLS:   tt.tls,
+			})
+			if err != nil {
+				t.Fatalf("client New() error: %v", err)
+			}
+			clientLogs := &lockedBuffer{}
+			clientEP.SetLogger(slog.NewTextHandler(clientLogs, nil))
+			clientEP.Run(true)
+			defer clientEP.Stop()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for !tt.logs(clientLogs.String(), serverLogs.String()) {
+				if time.Now().After(deadline) {
+					t.Fatal(tt.problem)
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			if clientEP.Running() {
+				t.Fatal("client connected without a verified certificate")
+			}
+			if clientData.String != "" {
+				t.Fatalf("data synced to unverified peer: %+v", clientData)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package endpoint
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/kjbreil/syncer/pkg/crdt"
+	"github.com/kjbreil/syncer/pkg/deepcopy"
+	settings2 "github.com/kjbreil/syncer/pkg/endpoint/settings"
+	"github.com/kjbreil/syncer/pkg/tags"
+)
+
+var (
+	ErrNotStruct        = errors.New("data must point to a struct")
+	ErrUnsupportedField = errors.New("field type cannot be synchronized")
+)
+
+// Typed is an Endpoint for data of type T. Read and Write hold the lock of the endpoint, which is
+// also held while changes from peers are applied.
+type Typed[T any] struct {
+	*Endpoint
+	data *T
+}
+
+// NewTyped creates a new Endpoint for the struct data points to. The field types of T are checked
+// up front, fields that cannot be synchronized must be tagged `syncer:"local"`.
+func NewTyped[T any](data *T, stngs *settings2.Settings) (*Typed[T], error) {
+	if data == nil {
+		return nil, errors.New("data cannot be nil")
+	}
+
+	err := validateType(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+
+	ep, err := New(data, stngs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Typed[T]{Endpoint: ep, data: data}, nil
+}
+
+// Read calls fn with the data while holding the read lock of the endpoint.
+func (t *Typed[T]) Read(fn func(data *T)) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	fn(t.data)
+}
+
+// Write calls fn with the data while holding the lock of the endpoint and sends the changes to the
+// connected peers.
+func (t *Typed[T]) Write(fn func(data *T)) {
+	defer t.Notify()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(t.data)
+}
+
+// Snapshot returns a deep copy of the data.
+func (t *Typed[T]) Snapshot() T {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return deepcopy.Any(*t.data)
+}
+
+// validateType checks the type is a struct and all its synchronized fields can be extracted and
+// injected.
+func validateType(typ reflect.Type) error {
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: got %s", ErrNotStruct, typ)
+	}
+	return validateFields(typ, typ.Name(), map[reflect.Type]bool{})
+}
+
+func validateFields(typ reflect.Type, path string, seen map[reflect.Type]bool) error {
+	if seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+	defer delete(seen, typ)
+
+	// mergeable values are synchronized as a whole
+	if crdt.Is(typ) {
+		return nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return fmt.Errorf("%w: %s is %s, tag it `syncer:\"local\"`", ErrUnsupportedField, path, typ)
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return validateFields(typ.Elem(), path, seen)
+	case reflect.Map:
+		switch typ.Key().Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.String, reflect.Bool, reflect.Float32, reflect.Float64:
+		default:
+			return fmt.Errorf("%w: %s has map key %s", ErrUnsupportedField, path, typ.Key())
+		}
+		return validateFields(typ.Elem(), path, seen)
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() || tags.Parse(field).Local {
+				continue
+			}
+			err := validateFields(field.Type, path+"."+field.Name, seen)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
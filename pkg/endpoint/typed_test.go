@@ -0,0 +1,137 @@
+package endpoint
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kjbreil/syncer/pkg/endpoint/settings"
+)
+
+type withChan struct {
+	Name    string
+	Updates chan string
+}
+
+type withLocalChan struct {
+	Name    string
+	Updates chan string `syncer:"local"`
+}
+
+type withStructKey struct {
+	Counts map[subStruct]int
+}
+
+func TestNewTyped_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func() error
+		want error
+	}{
+		{
+			name: "struct",
+			new:  func() error { _, err := NewTyped(&syncStruct{}, defaultSettings()); return err },
+		},
+		{
+			name: "not a struct",
+			new:  func() error { _, err := NewTyped(&map[string]int{}, defaultSettings()); return err },
+			want: ErrNotStruct,
+		},
+		{
+			name: "channel field",
+			new:  func() error { _, err := NewTyped(&withChan{}, defaultSettings()); return err },
+			want: ErrUnsupportedField,
+		},
+		{
+			name: "local channel field",
+			new:  func() error { _, err := NewTyped(&withLocalChan{}, defaultSettings()); return err },
+		},
+		{
+			name: "struct map key",
+			new:  func() error { _, err := NewTyped(&withStructKey{}, defaultSettings()); return err },
+			want: ErrUnsupportedField,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.new()
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("NewTyped() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestTyped_WriteSnapshot verifies writes are sent to the peer and snapshots are not changed by
+// later writes.
+func TestTyped_WriteSnapshot(t *testing.T) {
+	port := findFreePort(t)
+
+	serverEP, err := NewTyped(&syncStruct{Map: map[string]int{"a": 1}}, &settings.Settings{
+		Port:         port,
+		AutoUpdate:   true,
+		PollInterval: -1,
+	})
+	if err != nil {
+		t.Fatalf("server NewTyped() error: %v", err)
+	}
+	serverEP.Run(false)
+	defer serverEP.Stop()
+	waitForServer(t, serverEP.Endpoint)
+	time.Sleep(500 * time.Millisecond)
+
+	clientEP, err := NewTyped(&syncStruct{}, &settings.Settings{
+		Port:         port + 1,
+		Peers:        []net.TCPAddr{{IP: net.ParseIP("127.0.0.1"), Port: port}},
+		AutoUpdate:   true,
+		PollInterval: -1,
+	})
+	if err != nil {
+		t.Fatalf("client NewTyped() error: %v", err)
+	}
+	clientEP.Run(true)
+	defer clientEP.Stop()
+	waitForRunning2(t, clientEP.Endpoint)
+	time.Sleep(500 * time.Millisecond)
+
+	snapshot := serverEP.Snapshot()
+	serverEP.Write(func(d *syncStruct) {
+		d.String = "written"
+		d.Map["a"] = 2
+	})
+	if snapshot.String != "" || snapshot.Map["a"] != 1 {
+		t.Fatalf("snapshot changed by write: %+v", snapshot)
+	}
+
+	waitFor(t, func() bool {
+		var got string
+		clientEP.Read(func(d *syncStruct) {
+			got = d.String
+		})
+		return got == "written"
+	}, "write not received by client")
+}
+
+// TestTyped_WritePanics verifies the lock of the endpoint is released when fn panics.
+func TestTyped_WritePanics(t *testing.T) {
+	ep, err := NewTyped(&syncStruct{}, defaultSettings())
+	if err != nil {
+		t.Fatalf("NewTyped() error: %v", err)
+	}
+	func() {
+		defer func() { _ = recover() }()
+		ep.Write(func(data *syncStruct) { panic("write failed") })
+	}()
+
+	locked := make(chan struct{})
+	go func() {
+		ep.Write(func(data *syncStruct) { data.String = "written" })
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("lock not released after fn panicked")
+	}
+}
@@ -5,9 +5,10 @@ import (
 
 	"github.com/kjbreil/syncer/pkg/control"
 	"github.com/kjbreil/syncer/pkg/equal"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func extractArray(newValue, oldValue reflect.Value, upperType reflect.StructField, level int) (control.Entries, error) {
+func extractArray(newValue, oldValue reflect.Value, upperType reflect.StructField, level int, role tags.Role) (control.Entries, error) {
 	var entries control.Entries
 	level++
 	for i := 0; i < newValue.Len(); i++ {
@@ -16,7 +17,7 @@ func extractArray(newValue, oldValue reflect.Value, upperType reflect.StructFiel
 		if equal.Equal(newIndexValue, oldIndexValue) {
 			continue
 		}
-		additions, err := extract(newIndexValue, oldIndexValue, upperType, level, false)
+		additions, err := extract(newIndexValue, oldIndexValue, upperType, level, role, false)
 		if err != nil {
 			return nil, err
 		}
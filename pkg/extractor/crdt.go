@@ -0,0 +1,27 @@
+package extractor
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/crdt"
+	"github.com/kjbreil/syncer/pkg/tags"
+)
+
+// extractCRDT sends the whole state of a mergeable value when it changed, the injector merges it
+// instead of overwriting the value.
+func extractCRDT(newValue, oldValue reflect.Value, _ reflect.StructField, level int, _ tags.Role) (control.Entries, error) {
+	newState, err := crdt.Of(newValue).State()
+	if err != nil {
+		return nil, err
+	}
+	oldState, err := crdt.Of(oldValue).State()
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(newState, oldState) {
+		return nil, nil
+	}
+	return control.Entries{control.NewEntry(level, newState)}, nil
+}
@@ -6,10 +6,12 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/crdt"
 	"github.com/kjbreil/syncer/pkg/deepcopy"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-type extFn func(newValue, oldValue reflect.Value, upperType reflect.StructField, level int) (control.Entries, error)
+type extFn func(newValue, oldValue reflect.Value, upperType reflect.StructField, level int, role tags.Role) (control.Entries, error)
 
 // extFns is a map of reflect.Kind to their respective extraction function.
 var extFns map[reflect.Kind]extFn
@@ -62,6 +64,17 @@ func init() {
 // If the value of a field is unsupported, an error is returned.
 // The returned list of changes is thread-safe and can be modified concurrently.
 func (ext *Extractor) Entries(data any) (control.Entries, error) {
+	return ext.diff(data, true)
+}
+
+// Diff returns the changes between the data and the previous state like Entries but leaves the
+// previous state untouched, the changes are extracted again until the previous state is updated
+// with Previous.
+func (ext *Extractor) Diff(data any) (control.Entries, error) {
+	return ext.diff(data, false)
+}
+
+func (ext *Extractor) diff(data any, advance bool) (control.Entries, error) {
 	ext.mut.Lock()
 	defer ext.mut.Unlock()
 
@@ -70,7 +83,13 @@ func (ext *Extractor) Entries(data any) (control.Entries, error) {
 	}
 
 	// deep copy the current data as a point in time
+	if ext.lock != nil {
+		ext.lock.Lock()
+	}
 	pitData := deepcopy.Any(data)
+	if ext.lock != nil {
+		ext.lock.Unlock()
+	}
 
 	// check if ext.data is nil before proceeding
 	if ext.data != nil {
@@ -86,14 +105,16 @@ func (ext *Extractor) Entries(data any) (control.Entries, error) {
 		// recursively extract the changes between the current and previous states
 		entries, err := extract(newValue, oldValue, reflect.StructField{
 			Name: newValue.Type().Name(),
-		}, 0, true)
+		}, 0, ext.role, true)
 
 		if err != nil && !errors.Is(err, ErrUnsupportedType) {
 			return nil, err
 		}
 
 		// set the current state to the point in time data
-		ext.data = pitData
+		if advance {
+			ext.data = pitData
+		}
 
 		return entries, nil
 	}
@@ -103,14 +124,18 @@ func (ext *Extractor) Entries(data any) (control.Entries, error) {
 
 // extract recursively compares the current and previous states of a value and returns a list of
 // changes between them.
-func extract(newValue, oldValue reflect.Value, upperType reflect.StructField, level int, makeKey bool) (control.Entries, error) {
+func extract(newValue, oldValue reflect.Value, upperType reflect.StructField, level int, role tags.Role, makeKey bool) (control.Entries, error) {
 	if !oldValue.IsValid() {
 		oldValue = reflect.New(newValue.Type()).Elem()
 	}
 
-	if iFn, ok := extFns[newValue.Kind()]; ok {
+	iFn, ok := extFns[newValue.Kind()]
+	if newValue.IsValid() && crdt.Is(newValue.Type()) {
+		iFn = extractCRDT
+	}
+	if ok {
 		// if the value kind has a registered extraction function, use it
-		head, err := iFn(newValue, oldValue, upperType, level)
+		head, err := iFn(newValue, oldValue, upperType, level, role)
 		if err != nil {
 			return nil, err
 		}
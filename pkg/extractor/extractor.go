@@ -6,11 +6,15 @@ import (
 	"sync"
 
 	"github.com/kjbreil/syncer/pkg/deepcopy"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
 type Extractor struct {
 	data any
+	role tags.Role
 	mut  *sync.Mutex
+	// lock is held while the data is copied, when nil the data is copied without locking
+	lock sync.Locker
 }
 
 var (
@@ -41,6 +45,34 @@ func New(data any) (*Extractor, error) {
 	}, nil
 }
 
+// SetRole sets the side of the connection the extractor works for, fields not sent by that side
+// (see tags.Options) are left out of the entries.
+func (ext *Extractor) SetRole(role tags.Role) {
+	ext.mut.Lock()
+	defer ext.mut.Unlock()
+	ext.role = role
+}
+
+// SetLocker sets the lock held while the data passed to Entries is read, usually the read lock
+// of a sync.RWMutex shared with the code changing the data.
+func (ext *Extractor) SetLocker(l sync.Locker) {
+	ext.mut.Lock()
+	defer ext.mut.Unlock()
+	ext.lock = l
+}
+
+// Previous calls fn with a pointer to the previous state, the state Entries compares the data
+// against. Changes fn makes to it are not extracted by the next call to Entries.
+func (ext *Extractor) Previous(fn func(previous any) error) error {
+	ext.mut.Lock()
+	defer ext.mut.Unlock()
+
+	if ext.data == nil {
+		return nil
+	}
+	return fn(ext.data)
+}
+
 // Reset resets the data to its initial state.
 func (ext *Extractor) Reset() {
 	ext.mut.Lock()
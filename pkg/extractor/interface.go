@@ -4,9 +4,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func extractInterface(newValue, oldValue reflect.Value, upperType reflect.StructField, level int) (control.Entries, error) {
+func extractInterface(newValue, oldValue reflect.Value, upperType reflect.StructField, level int, role tags.Role) (control.Entries, error) {
 	// the base type of the interface is invalid on both new and old values, effectively equal
 	if !newValue.Elem().IsValid() && !oldValue.Elem().IsValid() {
 		return nil, nil
@@ -16,5 +17,5 @@ func extractInterface(newValue, oldValue reflect.Value, upperType reflect.Struct
 		return control.Entries{control.NewRemoveEntry(level)}, nil
 	}
 
-	return extract(newValue.Elem(), oldValue.Elem(), upperType, level, false)
+	return extract(newValue.Elem(), oldValue.Elem(), upperType, level, role, false)
 }
@@ -4,9 +4,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func extractInvalid(_, _ reflect.Value, _ reflect.StructField, _ int) (control.Entries, error) {
+func extractInvalid(_, _ reflect.Value, _ reflect.StructField, _ int, _ tags.Role) (control.Entries, error) {
 	panic("extractInvalid should not be called")
 	return nil, nil
 }
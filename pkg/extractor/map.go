@@ -4,9 +4,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func extractMap(newValue, oldValue reflect.Value, upperType reflect.StructField, level int) (control.Entries, error) {
+func extractMap(newValue, oldValue reflect.Value, upperType reflect.StructField, level int, role tags.Role) (control.Entries, error) {
 	level++
 
 	if newValue.IsNil() && !oldValue.IsNil() {
@@ -42,7 +43,7 @@ func extractMap(newValue, oldValue reflect.Value, upperType reflect.StructField,
 		newMapIndexValue = reflect.Indirect(newMapIndexValue)
 		oldMapIndexValue = reflect.Indirect(oldMapIndexValue)
 
-		additions, err := extract(newMapIndexValue, oldMapIndexValue, upperType, level, false)
+		additions, err := extract(newMapIndexValue, oldMapIndexValue, upperType, level, role, false)
 		if err != nil {
 			return nil, err
 		}
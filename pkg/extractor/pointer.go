@@ -4,9 +4,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func extractPointer(newValue, oldValue reflect.Value, upperValue reflect.StructField, level int) (control.Entries, error) {
+func extractPointer(newValue, oldValue reflect.Value, upperValue reflect.StructField, level int, role tags.Role) (control.Entries, error) {
 	if (!newValue.IsValid() || newValue.IsNil()) && (!oldValue.IsValid() || oldValue.IsNil()) {
 		return nil, nil
 	}
@@ -19,5 +20,5 @@ func extractPointer(newValue, oldValue reflect.Value, upperValue reflect.StructF
 		oldValue = reflect.New(newValue.Type()).Elem()
 	}
 
-	return extract(newValue.Elem(), oldValue.Elem(), upperValue, level, false)
+	return extract(newValue.Elem(), oldValue.Elem(), upperValue, level, role, false)
 }
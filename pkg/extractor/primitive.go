@@ -5,9 +5,10 @@ import (
 
 	"github.com/kjbreil/syncer/pkg/control"
 	"github.com/kjbreil/syncer/pkg/equal"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func extractPrimitive(newValue, oldValue reflect.Value, _ reflect.StructField, level int) (control.Entries, error) {
+func extractPrimitive(newValue, oldValue reflect.Value, _ reflect.StructField, level int, _ tags.Role) (control.Entries, error) {
 	if !equal.Equal(newValue, oldValue) {
 		entry := control.NewEntry(level, reflect.Indirect(newValue).Interface())
 		return control.Entries{entry}, nil
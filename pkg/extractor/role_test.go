@@ -0,0 +1,56 @@
+package extractor
+
+import (
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/tags"
+)
+
+type directed struct {
+	Both      string
+	ReadOnly  string `syncer:"readonly"`
+	WriteOnly string `syncer:"writeonly"`
+	Local     string `syncer:"local"`
+	Sub       directedSub
+}
+
+type directedSub struct {
+	ReadOnly string `syncer:"readonly"`
+}
+
+// TestExtractor_SetRole verifies only the fields sent by the role are extracted, including in nested structs.
+func TestExtractor_SetRole(t *testing.T) {
+	data := &directed{Both: "b", ReadOnly: "r", WriteOnly: "w", Local: "l", Sub: directedSub{ReadOnly: "sr"}}
+
+	tests := []struct {
+		role tags.Role
+		want []string
+	}{
+		{role: tags.Any, want: []string{"Both", "ReadOnly", "WriteOnly", "Sub.ReadOnly"}},
+		{role: tags.Server, want: []string{"Both", "ReadOnly", "Sub.ReadOnly"}},
+		{role: tags.Client, want: []string{"Both", "WriteOnly"}},
+	}
+	for _, tt := range tests {
+		ext, err := New(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ext.SetRole(tt.role)
+		entries, err := ext.Entries(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Path())
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("role %d: got paths %v, want %v", tt.role, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("role %d: got paths %v, want %v", tt.role, got, tt.want)
+			}
+		}
+	}
+}
@@ -5,9 +5,10 @@ import (
 
 	"github.com/kjbreil/syncer/pkg/control"
 	"github.com/kjbreil/syncer/pkg/equal"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func extractSlice(newValue, oldValue reflect.Value, upperType reflect.StructField, level int) (control.Entries, error) {
+func extractSlice(newValue, oldValue reflect.Value, upperType reflect.StructField, level int, role tags.Role) (control.Entries, error) {
 	if newValue.IsNil() && !oldValue.IsNil() {
 		return control.Entries{control.NewRemoveEntry(level)}, nil
 	}
@@ -45,7 +46,7 @@ func extractSlice(newValue, oldValue reflect.Value, upperType reflect.StructFiel
 		if equal.Equal(newIndexValue, oldIndexValue) {
 			continue
 		}
-		additions, err := extract(newIndexValue, oldIndexValue, upperType, level, false)
+		additions, err := extract(newIndexValue, oldIndexValue, upperType, level, role, false)
 		if err != nil {
 			return nil, err
 		}
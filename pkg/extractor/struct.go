@@ -5,9 +5,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func extractStruct(newValue, oldValue reflect.Value, _ reflect.StructField, level int) (control.Entries, error) {
+func extractStruct(newValue, oldValue reflect.Value, _ reflect.StructField, level int, role tags.Role) (control.Entries, error) {
 	// TODO: This should check if oldValue is valid and return a delete if it is
 	if !newValue.IsValid() {
 		return nil, fmt.Errorf("extractStruct: newValue is not valid")
@@ -20,16 +21,15 @@ func extractStruct(newValue, oldValue reflect.Value, _ reflect.StructField, leve
 	var entries control.Entries
 
 	for i := 0; i < newValue.NumField(); i++ {
-		// skip if extractor tag is set
-		etag := newValue.Type().Field(i).Tag.Get("extractor")
-		if etag == "-" {
+		// skip if the field is not sent by this side of the connection
+		if !tags.Parse(newValue.Type().Field(i)).Sends(role) {
 			continue
 		}
 		if !newValue.Field(i).CanInterface() {
 			continue
 		}
 		level++
-		fieldEntry, err := extract(newValue.Field(i), oldValue.Field(i), newValue.Type().Field(i), level, true)
+		fieldEntry, err := extract(newValue.Field(i), oldValue.Field(i), newValue.Type().Field(i), level, role, true)
 		if err != nil {
 			return nil, err
 		}
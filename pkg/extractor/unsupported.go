@@ -4,8 +4,9 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func extractUnsupported(_, _ reflect.Value, _ reflect.StructField, _ int) (control.Entries, error) {
+func extractUnsupported(_, _ reflect.Value, _ reflect.StructField, _ int, _ tags.Role) (control.Entries, error) {
 	return nil, nil
 }
@@ -4,9 +4,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func injectArray(va reflect.Value, entry *control.Entry) error {
+func injectArray(va reflect.Value, entry *control.Entry, role tags.Role) error {
 	indexInt := int(entry.GetCurrentIndex().GetInt64())
-	return add(va.Index(indexInt), entry.Advance())
+	return add(va.Index(indexInt), entry.Advance(), role)
 }
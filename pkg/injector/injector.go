@@ -4,17 +4,28 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync"
 
+	"github.com/kjbreil/syncer/pkg/acl"
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/crdt"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
 type Injector struct {
 	data any
+	acl  *acl.ACL
+	role tags.Role
+	// lock is held while an entry is applied, when nil entries are applied without locking
+	lock sync.Locker
 }
 
-var ErrNotPointer = errors.New("data is not a pointer")
+var (
+	ErrNotPointer = errors.New("data is not a pointer")
+	ErrRejected   = errors.New("entry rejected")
+)
 
-type injFn func(va reflect.Value, entry *control.Entry) error
+type injFn func(va reflect.Value, entry *control.Entry, role tags.Role) error
 
 var injFns map[reflect.Kind]injFn
 
@@ -69,8 +80,88 @@ func (inj *Injector) AddAll(entries control.Entries) error {
 	return nil
 }
 
+// SetRole sets the side of the connection the injector works for, entries for fields not accepted
+// by that side (see tags.Options) are rejected.
+func (inj *Injector) SetRole(role tags.Role) {
+	inj.role = role
+}
+
+// SetACL sets the access list checked for entries added from a peer.
+func (inj *Injector) SetACL(a *acl.ACL) {
+	inj.acl = a
+}
+
+// AddFrom adds a control entry written by peer to the data.
+// If the access list refuses the write an ErrRejected error is returned and the data is untouched.
+func (inj *Injector) AddFrom(peer string, entry *control.Entry) error {
+	if !inj.acl.Allowed(peer, entry) {
+		return fmt.Errorf("%w: %s may not write %s", ErrRejected, peer, entry.Path())
+	}
+	return inj.Add(entry)
+}
+
+// SetLocker sets the lock held while an entry is applied to the data, usually a sync.RWMutex
+// shared with the code reading the data.
+func (inj *Injector) SetLocker(l sync.Locker) {
+	inj.lock = l
+}
+
 // Add adds a control entry to the data.
 func (inj *Injector) Add(entry *control.Entry) error {
+	if inj.lock != nil {
+		inj.lock.Lock()
+		defer inj.lock.Unlock()
+	}
+	return inj.add(entry)
+}
+
+// AddSet adds the entries as a single change, when one of them fails the data is restored to the
+// state before the first entry was added and the error is returned.
+func (inj *Injector) AddSet(entries control.Entries) error {
+	return inj.addSet("", false, entries)
+}
+
+// AddSetFrom adds the entries written by peer as a single change. Entries refused by the access
+// list or the role of the injector are skipped and marked rejected with the reason, the returned
+// error then matches ErrRejected. When any other entry fails the data is restored to the state
+// before the first entry was added and only that error is returned.
+func (inj *Injector) AddSetFrom(peer string, entries control.Entries) error {
+	return inj.addSet(peer, true, entries)
+}
+
+func (inj *Injector) addSet(peer string, checkACL bool, entries control.Entries) error {
+	if inj.lock != nil {
+		inj.lock.Lock()
+		defer inj.lock.Unlock()
+	}
+
+	var rejections []error
+	// the changes are undone from the last entry added to the first
+	var undo []func()
+	for _, e := range entries {
+		var err error
+		if checkACL && !inj.acl.Allowed(peer, e) {
+			err = fmt.Errorf("%w: %s may not write %s", ErrRejected, peer, e.Path())
+		} else {
+			undo = append(undo, inj.undo(e))
+			err = inj.add(e)
+		}
+		if checkACL && errors.Is(err, ErrRejected) {
+			e.Rejected, e.Reason = true, err.Error()
+			rejections = append(rejections, err)
+			continue
+		}
+		if err != nil {
+			for i := len(undo) - 1; i >= 0; i-- {
+				undo[i]()
+			}
+			return err
+		}
+	}
+	return errors.Join(rejections...)
+}
+
+func (inj *Injector) add(entry *control.Entry) error {
 	v := reflect.ValueOf(inj.data)
 
 	// if it is a pointer follow to the real data
@@ -84,14 +175,19 @@ func (inj *Injector) Add(entry *control.Entry) error {
 		return fmt.Errorf("injector top level type mismatch %s  != %s", t.Name(), entry.GetKey()[entry.GetKeyI()].GetKey())
 	}
 
-	return add(v, entry)
+	return add(v, entry, inj.role)
 }
 
 // Add adds a control entry to the data. Based on the data type either travels down the key's or sets the value.
-func add(v reflect.Value, entry *control.Entry) error {
+func add(v reflect.Value, entry *control.Entry, role tags.Role) error {
+	// mergeable values are merged with the state in the entry instead of overwritten
+	if v.CanAddr() && crdt.Is(v.Type()) && entry.IsLastKeyIndex() && !entry.GetRemove() {
+		return crdt.Of(v).Merge(entry.GetValue().GetBytes())
+	}
+
 	var err error
 	if iFn, ok := injFns[v.Kind()]; ok {
-		err = iFn(v, entry)
+		err = iFn(v, entry, role)
 		if err != nil {
 			return err
 		}
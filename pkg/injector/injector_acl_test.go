@@ -0,0 +1,168 @@
+package injector
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/acl"
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/extractor"
+)
+
+type aclData struct {
+	Name  string
+	Owner string
+}
+
+func TestInjector_AddFrom(t *testing.T) {
+	data := &aclData{}
+	inj, err := New(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := acl.New(acl.Rule{Path: "Owner", Allow: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inj.SetACL(a)
+
+	name := control.NewEntry(2, "remote")
+	name.Key = []*control.Key{{Key: "aclData"}, {Key: "Name"}}
+	if err := inj.AddFrom("peer", name); err != nil {
+		t.Fatalf("AddFrom() error = %v", err)
+	}
+
+	owner := control.NewEntry(2, "remote")
+	owner.Key = []*control.Key{{Key: "aclData"}, {Key: "Owner"}}
+	if err := inj.AddFrom("peer", owner); !errors.Is(err, ErrRejected) {
+		t.Fatalf("AddFrom() error = %v, want ErrRejected", err)
+	}
+
+	// local writes are not checked
+	owner = control.NewEntry(2, "local")
+	owner.Key = []*control.Key{{Key: "aclData"}, {Key: "Owner"}}
+	if err := inj.Add(owner); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if data.Name != "remote" || data.Owner != "local" {
+		t.Fatalf("unexpected data %+v", data)
+	}
+}
+
+func TestInjector_AddSetFrom(t *testing.T) {
+	data := &aclData{Name: "old", Owner: "old"}
+	inj, err := New(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := acl.New(acl.Rule{Path: "Owner", Allow: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inj.SetACL(a)
+
+	entry := func(field string, v any) *control.Entry {
+		e := control.NewEntry(2, v)
+		e.Key = []*control.Key{{Key: "aclData"}, {Key: field}}
+		return e
+	}
+
+	// a failing entry restores the entries added before it
+	bad := entry("Name", "new")
+	bad.Key[0].Key = "other"
+	err = inj.AddSetFrom("peer", control.Entries{entry("Name", "new"), bad})
+	if err == nil || errors.Is(err, ErrRejected) {
+		t.Fatalf("AddSetFrom() error = %v, want a failure", err)
+	}
+	if data.Name != "old" || data.Owner != "old" {
+		t.Fatalf("data not restored %+v", data)
+	}
+
+	// rejected entries are skipped and marked
+	owner := entry("Owner", "new")
+	err = inj.AddSetFrom("peer", control.Entries{entry("Name", "new"), owner})
+	if !errors.Is(err, ErrRejected) {
+		t.Fatalf("AddSetFrom() error = %v, want ErrRejected", err)
+	}
+	if !owner.GetRejected() || owner.GetReason() == "" {
+		t.Fatalf("entry not marked rejected: %v", owner)
+	}
+	if data.Name != "new" || data.Owner != "old" {
+		t.Fatalf("unexpected data %+v", data)
+	}
+}
+
+type undoData struct {
+	String string
+	Ints   []int
+	Cut    []int
+	Map    map[string]int
+	Ptr    *undoInner
+	Nested map[string]*undoInner
+}
+
+type undoInner struct {
+	Name string
+	Next *undoInner
+}
+
+func newUndoData() *undoData {
+	return &undoData{
+		String: "a",
+		Ints:   []int{1, 2},
+		Cut:    []int{1, 2, 3},
+		Map:    map[string]int{"x": 1, "y": 2},
+		Ptr:    &undoInner{Name: "p"},
+		Nested: map[string]*undoInner{"k": {Name: "n"}},
+	}
+}
+
+// TestInjector_AddSetUndo verifies a failing entry sets back every change of the entries added
+// before it: values changed, map keys added and removed, slices grown and cut and pointers made.
+func TestInjector_AddSetUndo(t *testing.T) {
+	// entries are consumed when added, every set is extracted again
+	entries := func() control.Entries {
+		ext, err := extractor.New(newUndoData())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = ext.Entries(newUndoData()); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := ext.Entries(&undoData{
+			String: "b",
+			Ints:   []int{1, 3, 4},
+			Cut:    []int{1},
+			Map:    map[string]int{"x": 5, "z": 6},
+			Ptr:    &undoInner{Name: "q", Next: &undoInner{Name: "r"}},
+			Nested: map[string]*undoInner{"k": {Name: "m"}, "j": {Name: "new"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+
+	data := newUndoData()
+	inj, err := New(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := control.NewEntry(2, "bad")
+	bad.Key = []*control.Key{{Key: "other"}, {Key: "String"}}
+	if err = inj.AddSet(append(entries(), bad)); err == nil {
+		t.Fatal("AddSet() succeeded with a failing entry")
+	}
+	if !reflect.DeepEqual(data, newUndoData()) {
+		t.Fatalf("data not restored %+v", data)
+	}
+
+	if err = inj.AddSet(entries()); err != nil {
+		t.Fatalf("AddSet() error = %v", err)
+	}
+	if data.String != "b" || len(data.Ints) != 3 || len(data.Cut) != 1 || data.Ptr.Next == nil || data.Nested["j"] == nil {
+		t.Fatalf("entries not added %+v", data)
+	}
+}
@@ -0,0 +1,49 @@
+package injector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
+)
+
+type roleData struct {
+	ReadOnly  string `syncer:"readonly"`
+	WriteOnly string `syncer:"writeonly"`
+	Local     string `syncer:"local"`
+}
+
+func TestInjector_SetRole(t *testing.T) {
+	entry := func(field string) *control.Entry {
+		e := control.NewEntry(2, "value")
+		e.Key = []*control.Key{{Key: "roleData"}, {Key: field}}
+		return e
+	}
+
+	tests := []struct {
+		role   tags.Role
+		field  string
+		reject bool
+	}{
+		{role: tags.Server, field: "ReadOnly", reject: true},
+		{role: tags.Client, field: "ReadOnly"},
+		{role: tags.Server, field: "WriteOnly"},
+		{role: tags.Client, field: "WriteOnly", reject: true},
+		{role: tags.Client, field: "Local", reject: true},
+		{role: tags.Any, field: "Local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			inj, err := New(&roleData{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			inj.SetRole(tt.role)
+			err = inj.Add(entry(tt.field))
+			if tt.reject != errors.Is(err, ErrRejected) {
+				t.Fatalf("role %d field %s: error = %v, reject %v", tt.role, tt.field, err, tt.reject)
+			}
+		})
+	}
+}
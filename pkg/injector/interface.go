@@ -4,9 +4,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func injectInterface(va reflect.Value, entry *control.Entry) error {
+func injectInterface(va reflect.Value, entry *control.Entry, role tags.Role) error {
 	// if it's a remove and the last KeyIndex then nil out the value
 	if entry.GetRemove() && entry.IsLastKeyIndex() {
 		va.Set(reflect.Zero(va.Type()))
@@ -14,5 +15,5 @@ func injectInterface(va reflect.Value, entry *control.Entry) error {
 	}
 
 	va = va.Elem()
-	return add(va, entry)
+	return add(va, entry, role)
 }
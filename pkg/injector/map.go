@@ -6,9 +6,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func injectMap(va reflect.Value, entry *control.Entry) error {
+func injectMap(va reflect.Value, entry *control.Entry, role tags.Role) error {
 	if entry.GetCurrKey().HasNoIndex() {
 		// no index on a map key and remove type make map nil
 		if entry.GetRemove() {
@@ -44,7 +45,7 @@ func injectMap(va reflect.Value, entry *control.Entry) error {
 	}
 
 	// create a variable to hold the indexed value
-	mapValue, err := makeMapValue(va, entry, mapKey)
+	mapValue, err := makeMapValue(va, entry, mapKey, role)
 	if err != nil {
 		return err
 	}
@@ -68,7 +69,7 @@ func injectMap(va reflect.Value, entry *control.Entry) error {
 	return nil
 }
 
-func makeMapValue(va reflect.Value, entry *control.Entry, mapKey reflect.Value) (reflect.Value, error) {
+func makeMapValue(va reflect.Value, entry *control.Entry, mapKey reflect.Value, role tags.Role) (reflect.Value, error) {
 	mapValue := reflect.New(va.Type().Elem()).Elem()
 
 	// get the current value if it exits in the map
@@ -80,9 +81,9 @@ func makeMapValue(va reflect.Value, entry *control.Entry, mapKey reflect.Value)
 	var err error
 	switch mapValue.Kind() {
 	case reflect.Struct:
-		err = add(mapValue, entry)
+		err = add(mapValue, entry, role)
 	default:
-		err = add(mapValue, entry.Advance())
+		err = add(mapValue, entry.Advance(), role)
 	}
 	if err != nil {
 		return mapValue, err
@@ -92,23 +93,28 @@ func makeMapValue(va reflect.Value, entry *control.Entry, mapKey reflect.Value)
 }
 
 func makeMapKey(keyType reflect.Type, entry *control.Entry) (reflect.Value, error) {
+	return keyOf(keyType, entry.GetCurrentIndex())
+}
+
+// keyOf returns the index as a map key of keyType.
+func keyOf(keyType reflect.Type, idx *control.Object) (reflect.Value, error) {
 	// create a variable to hold the indexed key
 	var mapKey reflect.Value
 
 	// based on the key type, set the indexed key
 	switch keyType.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		mapKey = reflect.ValueOf(int(entry.GetCurrentIndex().GetInt64()))
+		mapKey = reflect.ValueOf(int(idx.GetInt64()))
 	case reflect.String:
-		mapKey = reflect.ValueOf(entry.GetCurrentIndex().GetString_())
+		mapKey = reflect.ValueOf(idx.GetString_())
 	case reflect.Bool:
-		mapKey = reflect.ValueOf(entry.GetCurrentIndex().GetBool())
+		mapKey = reflect.ValueOf(idx.GetBool())
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		mapKey = reflect.ValueOf(uint(entry.GetCurrentIndex().GetUint64()))
+		mapKey = reflect.ValueOf(uint(idx.GetUint64()))
 	case reflect.Float32:
-		mapKey = reflect.ValueOf(float32(entry.GetCurrentIndex().GetFloat32()))
+		mapKey = reflect.ValueOf(float32(idx.GetFloat32()))
 	case reflect.Float64:
-		mapKey = reflect.ValueOf(entry.GetCurrentIndex().GetFloat64())
+		mapKey = reflect.ValueOf(idx.GetFloat64())
 	default:
 		return reflect.Value{}, fmt.Errorf("cannot create key of type %s", keyType.Kind())
 	}
@@ -4,9 +4,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func injectPointer(va reflect.Value, entry *control.Entry) error {
+func injectPointer(va reflect.Value, entry *control.Entry, role tags.Role) error {
 	// if its a remove and the last KeyIndex then nil out the value
 	if entry.GetRemove() && entry.IsLastKeyIndex() {
 		va.Set(reflect.Zero(va.Type()))
@@ -18,5 +19,5 @@ func injectPointer(va reflect.Value, entry *control.Entry) error {
 		va.Set(newVa)
 	}
 
-	return add(va.Elem(), entry)
+	return add(va.Elem(), entry, role)
 }
@@ -5,9 +5,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func injectPrimitive(va reflect.Value, entry *control.Entry) error {
+func injectPrimitive(va reflect.Value, entry *control.Entry, _ tags.Role) error {
 	if va.CanSet() {
 		return entry.GetValue().SetValue(va)
 	}
@@ -5,9 +5,10 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func injectSlice(va reflect.Value, entry *control.Entry) error {
+func injectSlice(va reflect.Value, entry *control.Entry, role tags.Role) error {
 	// no index, either an error or full remove the slice
 	if entry.GetCurrKey().HasNoIndex() {
 		// no index on a map key and remove type make map nil
@@ -40,5 +41,5 @@ func injectSlice(va reflect.Value, entry *control.Entry) error {
 		va.Set(reflect.AppendSlice(va, newSlice))
 	}
 
-	return add(va.Index(indexInt), entry.Advance())
+	return add(va.Index(indexInt), entry.Advance(), role)
 }
@@ -5,12 +5,18 @@ import (
 	"reflect"
 
 	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/tags"
 )
 
-func injectStruct(va reflect.Value, entry *control.Entry) error {
+func injectStruct(va reflect.Value, entry *control.Entry, role tags.Role) error {
 	entry.Advance()
-	if structFieldValue := va.FieldByName(entry.GetCurrKeyString()); structFieldValue.IsValid() {
-		return add(structFieldValue, entry)
+	field, ok := va.Type().FieldByName(entry.GetCurrKeyString())
+	if !ok {
+		return fmt.Errorf("field %s not found in struct", entry.GetCurrKeyString())
 	}
-	return fmt.Errorf("field %s not found in struct", entry.GetCurrKeyString())
+	// without a role every field is injected, e.g. when restoring the data
+	if opts := tags.Parse(field); role != tags.Any && !opts.Accepts(role) {
+		return fmt.Errorf("%w: field %s is %s", ErrRejected, entry.GetCurrKeyString(), opts)
+	}
+	return add(va.FieldByIndex(field.Index), entry, role)
 }
@@ -0,0 +1,132 @@
+package injector
+
+import (
+	"reflect"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/deepcopy"
+)
+
+// location holds a value the injector changes, either a settable value or the element of a map at
+// key.
+type location struct {
+	v   reflect.Value
+	m   reflect.Value
+	key reflect.Value
+}
+
+// keep returns a function setting the location back to the value it holds now. Only a deep copy
+// survives changes made inside the value, a copy of the value itself is enough when it is replaced.
+func (l location) keep(deep bool) func() {
+	v := l.v
+	if l.m.IsValid() {
+		v = l.m.MapIndex(l.key)
+	} else if !v.CanSet() {
+		return func() {}
+	}
+	var old reflect.Value
+	if deep {
+		old = deepcopy.DeepCopy(v)
+	} else {
+		old = reflect.New(v.Type()).Elem()
+		old.Set(v)
+	}
+	if m, key := l.m, l.key; m.IsValid() {
+		return func() { m.SetMapIndex(key, old) }
+	}
+	return func() { v.Set(old) }
+}
+
+// undo returns a function setting back what adding the entry changes in the data, only the value
+// at the path of the entry is copied. Where the path ends early the entry creates the rest of it:
+// the map key is removed again or the slice, map or pointer it grows or makes is set back.
+func (inj *Injector) undo(e *control.Entry) func() {
+	cur := reflect.ValueOf(inj.data).Elem()
+	l := location{v: cur}
+	keys := e.GetKey()
+	for i, k := range keys {
+		var ok bool
+		if i > 0 {
+			if cur, l, ok = follow(cur, l); !ok {
+				return l.keep(false)
+			}
+			if cur.Kind() != reflect.Struct {
+				return l.keep(true)
+			}
+			cur = cur.FieldByName(k.GetKey())
+			if !cur.IsValid() {
+				return func() {}
+			}
+			if !l.m.IsValid() {
+				l = location{v: cur}
+			}
+		}
+		for j, idx := range k.GetIndex() {
+			if cur, l, ok = follow(cur, l); !ok {
+				return l.keep(false)
+			}
+			last := i == len(keys)-1 && j == len(k.GetIndex())-1
+			switch cur.Kind() {
+			case reflect.Map:
+				if cur.Len() == 0 {
+					// the injector makes a new map
+					return l.keep(false)
+				}
+				key, found := mapKey(cur, idx)
+				if !found {
+					m := cur
+					return func() {
+						if key.IsValid() {
+							m.SetMapIndex(key, reflect.Value{})
+						}
+					}
+				}
+				l = location{m: cur, key: key}
+				cur = cur.MapIndex(key)
+			case reflect.Slice:
+				n := int(idx.GetInt64())
+				if n >= cur.Len() || e.GetRemove() && last {
+					// the slice is grown or cut into a new slice
+					return l.keep(false)
+				}
+				cur = cur.Index(n)
+				l = location{v: cur}
+			case reflect.Array:
+				n := int(idx.GetInt64())
+				if n < 0 || n >= cur.Len() {
+					return func() {}
+				}
+				cur = cur.Index(n)
+				if !l.m.IsValid() {
+					l = location{v: cur}
+				}
+			default:
+				return l.keep(true)
+			}
+		}
+	}
+	return l.keep(!e.GetRemove())
+}
+
+// follow follows pointers and interfaces to the value they hold, it returns false at a nil.
+func follow(cur reflect.Value, l location) (reflect.Value, location, bool) {
+	for cur.Kind() == reflect.Pointer || cur.Kind() == reflect.Interface {
+		if cur.IsNil() {
+			return cur, l, false
+		}
+		cur = cur.Elem()
+		if cur.CanSet() {
+			l = location{v: cur}
+		}
+	}
+	return cur, l, true
+}
+
+// mapKey returns the key of the map for the index and if the map holds it.
+func mapKey(m reflect.Value, idx *control.Object) (reflect.Value, bool) {
+	key, err := keyOf(m.Type().Key(), idx)
+	if err != nil {
+		return reflect.Value{}, false
+	}
+	return key, m.MapIndex(key).IsValid()
+}
@@ -0,0 +1,315 @@
+// Package merkle hashes the data subtree by subtree, the subtrees are the fields, indexes and map
+// keys along the paths of its entries. Endpoints holding the same data have the same hashes, by
+// comparing the hashes from the top down they find the subtrees they differ in.
+package merkle
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrRepair = errors.New("could not repair from server")
+
+// Tree holds the hash of every subtree of the data by its path, the root has the empty path.
+type Tree struct {
+	nodes map[string]*node
+}
+
+type node struct {
+	hash     []byte
+	children []string
+	// leaves are the hashes of the entries at the path of the node
+	leaves [][]byte
+	// entries are the entries at or below the path of the node
+	entries control.Entries
+}
+
+// New builds the tree of the entries of the entire data, e.g. from combined.Combined.Snapshot.
+func New(entries control.Entries) *Tree {
+	root := &node{}
+	t := &Tree{nodes: map[string]*node{"": root}}
+	for _, e := range entries {
+		n := root
+		n.entries = append(n.entries, e)
+		for _, path := range e.Paths() {
+			child, ok := t.nodes[path]
+			if !ok {
+				child = &node{}
+				t.nodes[path] = child
+				n.children = append(n.children, path)
+			}
+			child.entries = append(child.entries, e)
+			n = child
+		}
+		n.leaves = append(n.leaves, leaf(e))
+	}
+	t.hash(root)
+	return t
+}
+
+// leaf returns the hash of an entry.
+func leaf(e *control.Entry) []byte {
+	h := sha256.New()
+	h.Write([]byte(e.Path()))
+	if e.GetRemove() {
+		h.Write([]byte{0, 1})
+	} else {
+		h.Write([]byte{0, 0})
+	}
+	value, _ := proto.MarshalOptions{Deterministic: true}.Marshal(e.GetValue())
+	h.Write(value)
+	return h.Sum(nil)
+}
+
+// hash sets the hashes of the node and the nodes below it.
+func (t *Tree) hash(n *node) []byte {
+	slices.Sort(n.children)
+	slices.SortFunc(n.leaves, bytes.Compare)
+	h := sha256.New()
+	for _, l := range n.leaves {
+		h.Write(l)
+	}
+	for _, path := range n.children {
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		h.Write(t.hash(t.nodes[path]))
+	}
+	n.hash = h.Sum(nil)
+	return n.hash
+}
+
+// Hash returns the hash of the subtree at path, nil when the data has nothing at path.
+func (t *Tree) Hash(path string) []byte {
+	if n, ok := t.nodes[path]; ok {
+		return n.hash
+	}
+	return nil
+}
+
+// Digest returns the digest of the subtree at path with the digests of its children, without a
+// hash when the data has nothing at path.
+func (t *Tree) Digest(path string) *control.Digest {
+	d := &control.Digest{Path: path}
+	n, ok := t.nodes[path]
+	if !ok {
+		return d
+	}
+	d.Hash = n.hash
+	for _, child := range n.children {
+		d.Children = append(d.Children, &control.Digest{Path: child, Hash: t.nodes[child].hash})
+	}
+	return d
+}
+
+// Entries returns the entries of the subtree at path.
+func (t *Tree) Entries(path string) control.Entries {
+	if n, ok := t.nodes[path]; ok {
+		return n.entries
+	}
+	return nil
+}
+
+// Diff compares the subtree at the path of the digest of another tree with the subtree of this
+// tree. It returns the paths of the children to compare next, the paths of the subtrees to take
+// from the other tree entirely and the paths of the subtrees only this tree has.
+func (t *Tree) Diff(other *control.Digest) (descend, pull, extra []string) {
+	path := other.GetPath()
+	n, ok := t.nodes[path]
+	switch {
+	case other.GetHash() == nil:
+		if ok {
+			extra = append(extra, path)
+		}
+		return nil, nil, extra
+	case !ok:
+		return nil, []string{path}, nil
+	case bytes.Equal(n.hash, other.GetHash()):
+		return nil, nil, nil
+	case len(other.GetChildren()) == 0 || len(n.children) == 0:
+		// the values at the path differ, the subtree is replaced entirely
+		return nil, []string{path}, slices.Clone(n.children)
+	}
+	children := make(map[string]bool, len(other.GetChildren()))
+	for _, child := range other.GetChildren() {
+		children[child.GetPath()] = true
+		switch mine := t.Hash(child.GetPath()); {
+		case mine == nil:
+			pull = append(pull, child.GetPath())
+		case !bytes.Equal(mine, child.GetHash()):
+			descend = append(descend, child.GetPath())
+		}
+	}
+	for _, child := range n.children {
+		if !children[child] {
+			extra = append(extra, child)
+		}
+	}
+	return descend, pull, extra
+}
+
+// Removals returns the entries removing the subtrees at the paths from the data of the tree. A map
+// key or slice index is removed, a value below a field only is set to its zero value.
+func (t *Tree) Removals(paths []string) control.Entries {
+	var cuts, zeros control.Entries
+	removed := make(map[string]bool)
+	for _, path := range paths {
+		for _, e := range t.Entries(path) {
+			paths := e.Paths()
+			depth := slices.Index(paths, path)
+			if depth < 0 {
+				continue
+			}
+			for depth < len(paths) && !strings.HasSuffix(paths[depth], "]") {
+				depth++
+			}
+			if depth == len(paths) {
+				zero := proto.Clone(e).(*control.Entry)
+				zero.Value, zero.Clock, zero.Origin = e.GetValue().Zero(), 0, ""
+				zeros = append(zeros, zero)
+				continue
+			}
+			if removed[paths[depth]] {
+				continue
+			}
+			removed[paths[depth]] = true
+			cut := e.Ancestor(depth + 1)
+			cut.Remove = true
+			cuts = append(cuts, cut)
+		}
+	}
+	// a slice is cut at an index, its last indexes are removed first so the cuts do not grow it
+	slices.SortFunc(cuts, func(a, b *control.Entry) int {
+		parentA, indexA := split(a.Path())
+		parentB, indexB := split(b.Path())
+		if c := strings.Compare(parentA, parentB); c != 0 {
+			return c
+		}
+		ia, errA := strconv.Atoi(indexA)
+		ib, errB := strconv.Atoi(indexB)
+		switch {
+		case errA == nil && errB == nil:
+			return ib - ia
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		}
+		return strings.Compare(indexB, indexA)
+	})
+	return append(cuts, zeros...)
+}
+
+// Stale returns the entries removing the subtrees of the tree other does not have, see Removals.
+func (t *Tree) Stale(other *Tree) control.Entries {
+	var extra []string
+	paths := []string{""}
+	for len(paths) > 0 {
+		var next []string
+		for _, path := range paths {
+			descend, _, x := t.Diff(other.Digest(path))
+			next = append(next, descend...)
+			extra = append(extra, x...)
+		}
+		paths = next
+	}
+	return t.Removals(extra)
+}
+
+// Restore returns the entries setting the path of the entry back to the data of the tree: the
+// entries of the subtree at the path, or when the tree has nothing there an entry removing the
+// first map key or slice index along the path the tree does not have, or setting the value at the
+// path to its zero value.
+func (t *Tree) Restore(e *control.Entry) control.Entries {
+	paths := e.Paths()
+	if len(paths) == 0 || e.GetValue() == nil && !e.GetRemove() {
+		return nil
+	}
+	if entries := t.Entries(paths[len(paths)-1]); len(entries) > 0 {
+		return entries
+	}
+	for depth, path := range paths {
+		if _, ok := t.nodes[path]; ok || !strings.HasSuffix(path, "]") {
+			continue
+		}
+		cut := e.Ancestor(depth + 1)
+		cut.Remove = true
+		return control.Entries{cut}
+	}
+	if e.GetValue() == nil {
+		// a field cannot be removed, there is nothing to set it back to
+		return nil
+	}
+	zero := e.Ancestor(len(paths))
+	zero.Value = e.GetValue().Zero()
+	return control.Entries{zero}
+}
+
+// split separates the last index from a path ending with one.
+func split(path string) (string, string) {
+	i := strings.LastIndexByte(path, '[')
+	return path[:i], strings.TrimSuffix(path[i+1:], "]")
+}
+
+// Repair compares the tree with the tree of the server of client from the top down and returns the
+// entries changing the data of the tree into the data of the server: the removals of the subtrees
+// only the tree has followed by the entries of the subtrees that differ. synced is called with the
+// session and last change set of the server the digests are of, when it returns false the change
+// sets are not all applied to the data of the tree yet and nothing is returned.
+func (t *Tree) Repair(ctx context.Context, client control.ControlClient, synced func(session string, seq uint64) bool) (control.Entries, error) {
+	var pull, extra []string
+	paths := []string{""}
+	for len(paths) > 0 {
+		req := &control.Digests{}
+		for _, path := range paths {
+			req.Digests = append(req.Digests, &control.Digest{Path: path})
+		}
+		resp, err := client.Digest(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRepair, err)
+		}
+		if !synced(resp.GetSession(), resp.GetSeq()) {
+			return nil, nil
+		}
+		paths = nil
+		for _, d := range resp.GetDigests() {
+			descend, p, x := t.Diff(d)
+			paths = append(paths, descend...)
+			pull = append(pull, p...)
+			extra = append(extra, x...)
+		}
+	}
+
+	entries := t.Removals(extra)
+	if len(pull) == 0 {
+		return entries, nil
+	}
+	req := &control.Digests{}
+	for _, path := range pull {
+		req.Digests = append(req.Digests, &control.Digest{Path: path})
+	}
+	stream, err := client.Subtrees(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRepair, err)
+	}
+	for {
+		e, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRepair, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,142 @@
+package merkle
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/extractor"
+)
+
+type testData struct {
+	String string
+	Ints   []int
+	Map    map[string]int
+}
+
+func tree(t *testing.T, data *testData) *Tree {
+	t.Helper()
+	ext, err := extractor.New(&testData{})
+	if err != nil {
+		t.Fatalf("extractor.New() error: %v", err)
+	}
+	entries, err := ext.Entries(data)
+	if err != nil {
+		t.Fatalf("Entries() error: %v", err)
+	}
+	return New(entries)
+}
+
+func TestTree_Hash(t *testing.T) {
+	a := tree(t, &testData{String: "a", Ints: []int{1, 2}, Map: map[string]int{"x": 1, "y": 2}})
+	b := tree(t, &testData{String: "a", Ints: []int{1, 2}, Map: map[string]int{"y": 2, "x": 1}})
+	if !bytes.Equal(a.Hash(""), b.Hash("")) {
+		t.Fatalf("equal data has different hashes")
+	}
+	c := tree(t, &testData{String: "a", Ints: []int{1, 3}, Map: map[string]int{"x": 1, "y": 2}})
+	if bytes.Equal(a.Hash(""), c.Hash("")) {
+		t.Fatalf("different data has equal hashes")
+	}
+	if !bytes.Equal(a.Hash("Map"), c.Hash("Map")) {
+		t.Fatalf("equal subtrees have different hashes")
+	}
+	if a.Hash("Missing") != nil {
+		t.Fatalf("Hash() of a missing path is not nil")
+	}
+}
+
+func TestTree_Diff(t *testing.T) {
+	server := tree(t, &testData{String: "a", Ints: []int{1, 2}, Map: map[string]int{"x": 1, "y": 2}})
+	local := tree(t, &testData{String: "a", Ints: []int{1, 2}, Map: map[string]int{"x": 3, "z": 4}})
+
+	descend, pull, extra := local.Diff(server.Digest(""))
+	if !slices.Equal(descend, []string{"Map"}) || len(pull) > 0 || len(extra) > 0 {
+		t.Fatalf("Diff() of the root = %v, %v, %v", descend, pull, extra)
+	}
+	descend, pull, extra = local.Diff(server.Digest("Map"))
+	if !slices.Equal(descend, []string{"Map[x]"}) || !slices.Equal(pull, []string{"Map[y]"}) || !slices.Equal(extra, []string{"Map[z]"}) {
+		t.Fatalf("Diff() of Map = %v, %v, %v", descend, pull, extra)
+	}
+	descend, pull, extra = local.Diff(server.Digest("Map[x]"))
+	if len(descend) > 0 || !slices.Equal(pull, []string{"Map[x]"}) || len(extra) > 0 {
+		t.Fatalf("Diff() of Map[x] = %v, %v, %v", descend, pull, extra)
+	}
+	descend, pull, extra = local.Diff(server.Digest("Map[z]"))
+	if len(descend) > 0 || len(pull) > 0 || !slices.Equal(extra, []string{"Map[z]"}) {
+		t.Fatalf("Diff() of Map[z] = %v, %v, %v", descend, pull, extra)
+	}
+}
+
+func TestTree_Removals(t *testing.T) {
+	local := tree(t, &testData{String: "a", Ints: []int{1, 2, 3, 4}, Map: map[string]int{"x": 1}})
+
+	removals := local.Removals([]string{"Ints[1]", "Ints[3]", "Map[x]", "String"})
+	var paths []string
+	for _, e := range removals {
+		paths = append(paths, e.Path())
+		if e.Path() == "String" {
+			if e.GetRemove() || e.GetValue().GetString_() != "" {
+				t.Fatalf("String is not set to its zero value: %v", e)
+			}
+		} else if !e.GetRemove() {
+			t.Fatalf("%s is not removed", e.Path())
+		}
+	}
+	// the last index of a slice is removed first
+	if want := []string{"Ints[3]", "Ints[1]", "Map[x]", "String"}; !slices.Equal(paths, want) {
+		t.Fatalf("Removals() = %v, want %v", paths, want)
+	}
+	if local.Removals([]string{"Missing"}) != nil {
+		t.Fatalf("Removals() of a missing path is not empty")
+	}
+}
+
+func TestTree_Stale(t *testing.T) {
+	server := tree(t, &testData{String: "a", Ints: []int{1}, Map: map[string]int{"x": 1}})
+	local := tree(t, &testData{String: "a", Ints: []int{1, 2, 3}, Map: map[string]int{"x": 2, "y": 3}})
+
+	var paths []string
+	for _, e := range local.Stale(server) {
+		if !e.GetRemove() {
+			t.Fatalf("%s is not removed", e.Path())
+		}
+		paths = append(paths, e.Path())
+	}
+	if want := []string{"Ints[2]", "Ints[1]", "Map[y]"}; !slices.Equal(paths, want) {
+		t.Fatalf("Stale() = %v, want %v", paths, want)
+	}
+	if stale := server.Stale(server); len(stale) > 0 {
+		t.Fatalf("Stale() of the same tree = %v", stale)
+	}
+}
+
+func TestTree_Restore(t *testing.T) {
+	server := tree(t, &testData{String: "a", Ints: []int{1, 2}, Map: map[string]int{"x": 1}})
+	local := tree(t, &testData{String: "b", Ints: []int{1, 2, 3}, Map: map[string]int{"x": 2, "y": 3}})
+
+	tests := []struct {
+		path   string
+		want   string
+		remove bool
+	}{
+		{path: "String", want: "String"},
+		{path: "Map[x]", want: "Map[x]"},
+		{path: "Map[y]", want: "Map[y]", remove: true},
+		{path: "Ints[2]", want: "Ints[2]", remove: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			entries := local.Entries(tt.path)
+			if len(entries) != 1 {
+				t.Fatalf("local entries at %s = %v", tt.path, entries)
+			}
+			restore := server.Restore(entries[0])
+			if len(restore) != 1 || restore[0].Path() != tt.want || restore[0].GetRemove() != tt.remove {
+				t.Fatalf("Restore() = %v", restore)
+			}
+			if !tt.remove && !bytes.Equal(leaf(restore[0]), leaf(server.Entries(tt.path)[0])) {
+				t.Fatalf("Restore() = %v, want the value of the tree", restore)
+			}
+		})
+	}
+}
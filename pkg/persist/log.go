@@ -0,0 +1,250 @@
+package persist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// logHeader is the size of the header of a record: the payload length, the checksum and the
+	// sequence number.
+	logHeader = 16
+	// maxRecord is the largest payload accepted, a larger length is a corrupt header.
+	maxRecord = 64 << 20
+)
+
+var ErrLogRecord = errors.New("invalid log record")
+
+// Log is an append-only log of entries. Every entry is written as a record holding its sequence
+// number and a CRC-32C checksum so records torn by a crash are detected and dropped on open. A
+// compacted log starts with a record without an entry holding the last sequence number removed,
+// the records appended after reopening it keep counting from there.
+type Log struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	seq  uint64
+}
+
+// record is a record of the log, entry is nil for the record a compacted log starts with.
+type record struct {
+	seq   uint64
+	entry *control.Entry
+}
+
+// OpenLog opens the log at path, creating it when it does not exist. A torn or corrupt record at the
+// end of the log, left by a crash while appending, is truncated.
+func OpenLog(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	records, valid, err := readRecords(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	// drop everything after the last valid record
+	err = file.Truncate(valid)
+	if err == nil {
+		_, err = file.Seek(valid, io.SeekStart)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	l := &Log{path: path, file: file}
+	if len(records) > 0 {
+		l.seq = records[len(records)-1].seq
+	}
+	return l, nil
+}
+
+// Append writes the entries to the log, each with the next sequence number, and syncs the log.
+func (l *Log) Append(entries ...*control.Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var buf []byte
+	seq := l.seq
+	for _, e := range entries {
+		seq++
+		b, err := encodeRecord(seq, e)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, b...)
+	}
+	_, err := l.file.Write(buf)
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		return err
+	}
+	l.seq = seq
+	return nil
+}
+
+// Seq returns the sequence number of the last record appended.
+func (l *Log) Seq() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
+
+// Replay calls fn in order for every entry with a sequence number after seq.
+func (l *Log) Replay(seq uint64, fn func(*control.Entry) error) error {
+	l.mu.Lock()
+	records, err := l.records()
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if r.seq <= seq || r.entry == nil {
+			continue
+		}
+		err = fn(r.entry)
+		if err != nil {
+			return fmt.Errorf("replaying record %d: %w", r.seq, err)
+		}
+	}
+	return nil
+}
+
+// Compact removes the records up to and including seq, usually after they were saved in a
+// snapshot. The log is rewritten to a temporary file that is renamed over the log.
+func (l *Log) Compact(seq uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records, err := l.records()
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	if seq > 0 {
+		// the sequence numbers continue after seq when the log is opened again
+		buf, err = encodeRecord(seq, nil)
+		if err != nil {
+			return err
+		}
+	}
+	for _, r := range records {
+		if r.seq <= seq || r.entry == nil {
+			continue
+		}
+		b, err := encodeRecord(r.seq, r.entry)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, b...)
+	}
+	err = writeAtomic(l.path, buf)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_ = l.file.Close()
+	l.file = file
+	return nil
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// records reads every record of the log, the lock must be held.
+func (l *Log) records() ([]record, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, _, err := readRecords(file)
+	return records, err
+}
+
+// encodeRecord encodes the record of the entry, a nil entry has an empty payload.
+func encodeRecord(seq uint64, e *control.Entry) ([]byte, error) {
+	var payload []byte
+	if e != nil {
+		var err error
+		payload, err = proto.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode entry %s: %w", e.Path(), err)
+		}
+	}
+	b := make([]byte, logHeader, logHeader+len(payload))
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint64(b[8:16], seq)
+	b = append(b, payload...)
+	binary.BigEndian.PutUint32(b[4:8], crc32.Checksum(b[8:], crcTable))
+	return b, nil
+}
+
+// readRecords reads records from the start of the file until the end or the first torn or corrupt
+// record and returns the records and the offset after the last valid one.
+func readRecords(file *os.File) ([]record, int64, error) {
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var records []record
+	var valid int64
+	r := bufio.NewReader(file)
+	header := make([]byte, logHeader)
+	for {
+		_, err = io.ReadFull(r, header)
+		if err != nil {
+			// io.EOF is a clean end, io.ErrUnexpectedEOF a torn header
+			return records, valid, nil
+		}
+		size := binary.BigEndian.Uint32(header[0:4])
+		sum := binary.BigEndian.Uint32(header[4:8])
+		seq := binary.BigEndian.Uint64(header[8:16])
+		if size > maxRecord {
+			return records, valid, nil
+		}
+
+		payload := make([]byte, size)
+		_, err = io.ReadFull(r, payload)
+		if err != nil {
+			return records, valid, nil
+		}
+		if crc32.Update(crc32.Checksum(header[8:16], crcTable), crcTable, payload) != sum {
+			return records, valid, nil
+		}
+		valid += int64(logHeader) + int64(size)
+		if size == 0 {
+			records = append(records, record{seq: seq})
+			continue
+		}
+		e := &control.Entry{}
+		err = proto.Unmarshal(payload, e)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w %d: %w", ErrLogRecord, seq, err)
+		}
+		records = append(records, record{seq: seq, entry: e.Rewind()})
+	}
+}
@@ -0,0 +1,166 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kjbreil/syncer/pkg/control"
+)
+
+func nameEntry(name string) *control.Entry {
+	e := control.NewEntry(2, name)
+	e.Key = []*control.Key{{Key: "state"}, {Key: "Name"}}
+	return e
+}
+
+// replayed returns the names of the entries replayed after seq.
+func replayed(t *testing.T, l *Log, seq uint64) []string {
+	t.Helper()
+	var names []string
+	err := l.Replay(seq, func(e *control.Entry) error {
+		names = append(names, e.GetValue().GetString_())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestLog_AppendReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.log")
+	l, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Append(nameEntry("a"), nameEntry("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Append(nameEntry("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Seq() != 3 {
+		t.Fatalf("Seq() = %d, want 3", l.Seq())
+	}
+	_ = l.Close()
+
+	l, err = OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.Seq() != 3 {
+		t.Fatalf("Seq() after reopen = %d, want 3", l.Seq())
+	}
+	if got := replayed(t, l, 1); !slices.Equal(got, []string{"b", "c"}) {
+		t.Fatalf("replayed %v, want [b c]", got)
+	}
+
+	// replayed entries can be applied
+	data := &state{}
+	err = l.Replay(0, func(e *control.Entry) error {
+		return Restore(data, nil, control.Entries{e})
+	})
+	if err != nil || data.Name != "c" {
+		t.Fatalf("replay applied %q, error %v", data.Name, err)
+	}
+}
+
+// TestLog_TornTail verifies a record cut short by a crash is dropped and the log stays usable.
+func TestLog_TornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.log")
+	l, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Append(nameEntry("a"), nameEntry("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Truncate(path, info.Size()-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if got := replayed(t, l, 0); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("replayed %v, want [a]", got)
+	}
+	err = l.Append(nameEntry("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayed(t, l, 0); !slices.Equal(got, []string{"a", "c"}) || l.Seq() != 2 {
+		t.Fatalf("replayed %v with seq %d, want [a c] with seq 2", got, l.Seq())
+	}
+}
+
+func TestLog_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.log")
+	l, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	err = l.Append(nameEntry("a"), nameEntry("b"), nameEntry("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Compact(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Append(nameEntry("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayed(t, l, 0); !slices.Equal(got, []string{"c", "d"}) || l.Seq() != 4 {
+		t.Fatalf("replayed %v with seq %d, want [c d] with seq 4", got, l.Seq())
+	}
+}
+
+// TestLog_CompactReopen verifies the records appended after reopening a log compacted to nothing
+// keep counting after the records removed, they are replayed after a snapshot of them.
+func TestLog_CompactReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.log")
+	l, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Append(nameEntry("a"), nameEntry("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Compact(l.Seq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+
+	l, err = OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	err = l.Append(nameEntry("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayed(t, l, 2); !slices.Equal(got, []string{"c"}) || l.Seq() != 3 {
+		t.Fatalf("replayed %v with seq %d, want [c] with seq 3", got, l.Seq())
+	}
+}
@@ -0,0 +1,156 @@
+package persist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/kjbreil/syncer/pkg/control"
+	"github.com/kjbreil/syncer/pkg/extractor"
+	"github.com/kjbreil/syncer/pkg/injector"
+	"github.com/kjbreil/syncer/pkg/tags"
+	"google.golang.org/protobuf/proto"
+)
+
+// SnapshotVersion is the version of the snapshot format written by WriteSnapshot.
+const SnapshotVersion uint32 = 2
+
+// snapshotMagic starts every snapshot file.
+var snapshotMagic = []byte("SYNCSNAP")
+
+var (
+	ErrSnapshotFormat   = errors.New("not a snapshot file")
+	ErrSnapshotVersion  = errors.New("unsupported snapshot version")
+	ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Snapshot returns the entries describing the full state of data. The lock is held while data is
+// copied, it can be nil.
+func Snapshot(data any, lock sync.Locker) (control.Entries, error) {
+	ext, err := extractor.New(data)
+	if err != nil {
+		return nil, err
+	}
+	if lock != nil {
+		ext.SetLocker(lock)
+	}
+	// the extractor starts from the zero value so the first entries hold everything
+	return ext.Entries(data)
+}
+
+// Zero sets the fields of data saved by Snapshot to their zero value, before restoring a snapshot
+// into data. Fields tagged local keep their value. The lock is held while data is changed, it can
+// be nil.
+func Zero(data any, lock sync.Locker) {
+	if lock != nil {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		v.SetZero()
+		return
+	}
+	for i := range v.NumField() {
+		if f := v.Field(i); f.CanSet() && !tags.Parse(v.Type().Field(i)).Local {
+			f.SetZero()
+		}
+	}
+}
+
+// Restore applies the entries to data. The lock is held while the entries are applied, it can be
+// nil.
+func Restore(data any, lock sync.Locker, entries control.Entries) error {
+	inj, err := injector.New(data)
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		inj.SetLocker(lock)
+	}
+	return inj.AddAll(entries)
+}
+
+// WriteSnapshot writes the entries to file with seq, the sequence number of the last Log record
+// included in the entries. The snapshot is written to a temporary file that is renamed over file
+// so a crash never leaves a partial snapshot behind.
+//
+// The format is the magic SYNCSNAP, the version as a big endian uint32, seq as a big endian uint64,
+// the entries each prefixed with their uvarint encoded length and finally the CRC-32C of
+// everything before it.
+func WriteSnapshot(file string, seq uint64, entries control.Entries) error {
+	var buf bytes.Buffer
+	buf.Write(snapshotMagic)
+	buf.Write(binary.BigEndian.AppendUint32(nil, SnapshotVersion))
+	buf.Write(binary.BigEndian.AppendUint64(nil, seq))
+	for _, e := range entries {
+		b, err := proto.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("could not encode entry %s: %w", e.Path(), err)
+		}
+		buf.Write(binary.AppendUvarint(nil, uint64(len(b))))
+		buf.Write(b)
+	}
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc32.Checksum(buf.Bytes(), crcTable)))
+
+	return writeAtomic(file, buf.Bytes())
+}
+
+// ReadSnapshot reads the sequence number and entries written by WriteSnapshot. When file does not
+// exist the returned error matches os.ErrNotExist.
+func ReadSnapshot(file string) (uint64, control.Entries, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	header := len(snapshotMagic) + 4 + 8
+	if len(b) < header+4 || !bytes.Equal(b[:len(snapshotMagic)], snapshotMagic) {
+		return 0, nil, fmt.Errorf("%w: %s", ErrSnapshotFormat, file)
+	}
+	version := binary.BigEndian.Uint32(b[len(snapshotMagic) : len(snapshotMagic)+4])
+	if version != SnapshotVersion {
+		return 0, nil, fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+	body, sum := b[:len(b)-4], binary.BigEndian.Uint32(b[len(b)-4:])
+	if crc32.Checksum(body, crcTable) != sum {
+		return 0, nil, fmt.Errorf("%w: %s", ErrSnapshotChecksum, file)
+	}
+
+	seq := binary.BigEndian.Uint64(body[header-8 : header])
+
+	var entries control.Entries
+	r := bufio.NewReader(bytes.NewReader(body[header:]))
+	for {
+		size, err := binary.ReadUvarint(r)
+		if errors.Is(err, io.EOF) {
+			return seq, entries, nil
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: %w", ErrSnapshotFormat, err)
+		}
+		if size > uint64(len(body)) {
+			return 0, nil, fmt.Errorf("%w: entry larger than the snapshot", ErrSnapshotFormat)
+		}
+		eb := make([]byte, size)
+		_, err = io.ReadFull(r, eb)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: %w", ErrSnapshotFormat, err)
+		}
+		e := &control.Entry{}
+		err = proto.Unmarshal(eb, e)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: %w", ErrSnapshotFormat, err)
+		}
+		entries = append(entries, e)
+	}
+}
@@ -0,0 +1,116 @@
+package persist
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type state struct {
+	Name   string
+	Count  int
+	Tags   []string
+	Limits map[string]float64
+	Sub    *state
+	Local  string `syncer:"local"`
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state.snap")
+	data := &state{
+		Name:   "device",
+		Count:  3,
+		Tags:   []string{"a", "b"},
+		Limits: map[string]float64{"max": 1.5},
+		Sub:    &state{Name: "sub"},
+		Local:  "not saved",
+	}
+
+	entries, err := Snapshot(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteSnapshot(file, 7, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, read, err := ReadSnapshot(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 7 {
+		t.Fatalf("read sequence %d, want 7", seq)
+	}
+
+	// what the snapshot does not hold is zeroed, local fields are kept
+	restored := &state{Count: 9, Tags: []string{"a", "b", "stale"}, Limits: map[string]float64{"min": 1}, Local: "kept"}
+	Zero(restored, nil)
+	err = Restore(restored, nil, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := *data
+	want.Local = "kept"
+	if !reflect.DeepEqual(*restored, want) {
+		t.Fatalf("restored %+v, want %+v", restored, want)
+	}
+
+	matches, _ := filepath.Glob(file + ".tmp*")
+	if len(matches) != 0 {
+		t.Fatalf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestReadSnapshot_Errors(t *testing.T) {
+	dir := t.TempDir()
+	entries, err := Snapshot(&state{Name: "device"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := filepath.Join(dir, "valid.snap")
+	err = WriteSnapshot(valid, 0, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte{}, b...)
+	corrupt[len(snapshotMagic)+5] ^= 0xff
+	future := append([]byte{}, b...)
+	binary.BigEndian.PutUint32(future[len(snapshotMagic):], SnapshotVersion+1)
+	old := append([]byte{}, b...)
+	binary.BigEndian.PutUint32(old[len(snapshotMagic):], 1)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "checksum", data: corrupt, want: ErrSnapshotChecksum},
+		{name: "version", data: future, want: ErrSnapshotVersion},
+		{name: "version 1", data: old, want: ErrSnapshotVersion},
+		{name: "format", data: []byte("not a snapshot"), want: ErrSnapshotFormat},
+		{name: "missing", want: os.ErrNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.name+".snap")
+			if tt.data != nil {
+				err := os.WriteFile(file, tt.data, 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, _, err := ReadSnapshot(file)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ReadSnapshot() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
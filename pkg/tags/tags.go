@@ -0,0 +1,107 @@
+package tags
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Key is the struct tag key holding the sync options of a field.
+const Key = "syncer"
+
+const (
+	readOnly  = "readonly"
+	writeOnly = "writeonly"
+	local     = "local"
+	conflict  = "conflict="
+)
+
+// Role is the side of the connection an extractor or injector works for.
+type Role int
+
+const (
+	// Any is used when the side is not known, every field not marked local is synced both ways.
+	Any Role = iota
+	// Server is the side accepting connections.
+	Server
+	// Client is the side connecting to a server.
+	Client
+)
+
+// Options are the sync options of a struct field, parsed from a tag like `syncer:"readonly"`.
+type Options struct {
+	// ReadOnly fields are only sent from the server to the clients.
+	ReadOnly bool
+	// WriteOnly fields are only sent from the clients to the server.
+	WriteOnly bool
+	// Local fields are never synced, `extractor:"-"` is an alias.
+	Local bool
+	// Conflict names the resolver of conflicting writes to the field and the values below it,
+	// parsed from an option like conflict=server-wins. Empty when not set.
+	Conflict string
+}
+
+// Parse returns the sync options of the field.
+func Parse(field reflect.StructField) Options {
+	var o Options
+	if field.Tag.Get("extractor") == "-" {
+		o.Local = true
+	}
+	for _, opt := range strings.Split(field.Tag.Get(Key), ",") {
+		opt = strings.TrimSpace(opt)
+		if name, ok := strings.CutPrefix(opt, conflict); ok {
+			o.Conflict = name
+			continue
+		}
+		switch opt {
+		case readOnly:
+			o.ReadOnly = true
+		case writeOnly:
+			o.WriteOnly = true
+		case local, "-":
+			o.Local = true
+		}
+	}
+	return o
+}
+
+// Sends reports if the side with the role sends changes of the field to its peers.
+func (o Options) Sends(r Role) bool {
+	switch {
+	case o.Local:
+		return false
+	case o.ReadOnly:
+		return r != Client
+	case o.WriteOnly:
+		return r != Server
+	default:
+		return true
+	}
+}
+
+// Accepts reports if the side with the role applies changes of the field received from its peers.
+func (o Options) Accepts(r Role) bool {
+	switch {
+	case o.Local:
+		return false
+	case o.ReadOnly:
+		return r != Server
+	case o.WriteOnly:
+		return r != Client
+	default:
+		return true
+	}
+}
+
+// String returns the tag option describing the direction of the field.
+func (o Options) String() string {
+	switch {
+	case o.Local:
+		return local
+	case o.ReadOnly:
+		return readOnly
+	case o.WriteOnly:
+		return writeOnly
+	default:
+		return ""
+	}
+}
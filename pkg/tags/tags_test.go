@@ -0,0 +1,85 @@
+package tags
+
+import (
+	"reflect"
+	"testing"
+)
+
+type tagged struct {
+	Both      string
+	ReadOnly  string `syncer:"readonly"`
+	WriteOnly string `syncer:"writeonly"`
+	Local     string `syncer:"local"`
+	Excluded  string `extractor:"-"`
+}
+
+func TestOptions(t *testing.T) {
+	typ := reflect.TypeOf(tagged{})
+	tests := []struct {
+		field   string
+		sends   map[Role]bool
+		accepts map[Role]bool
+	}{
+		{
+			field:   "Both",
+			sends:   map[Role]bool{Any: true, Server: true, Client: true},
+			accepts: map[Role]bool{Any: true, Server: true, Client: true},
+		},
+		{
+			field:   "ReadOnly",
+			sends:   map[Role]bool{Any: true, Server: true, Client: false},
+			accepts: map[Role]bool{Any: true, Server: false, Client: true},
+		},
+		{
+			field:   "WriteOnly",
+			sends:   map[Role]bool{Any: true, Server: false, Client: true},
+			accepts: map[Role]bool{Any: true, Server: true, Client: false},
+		},
+		{
+			field:   "Local",
+			sends:   map[Role]bool{Any: false, Server: false, Client: false},
+			accepts: map[Role]bool{Any: false, Server: false, Client: false},
+		},
+		{
+			field:   "Excluded",
+			sends:   map[Role]bool{Any: false, Server: false, Client: false},
+			accepts: map[Role]bool{Any: false, Server: false, Client: false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			f, _ := typ.FieldByName(tt.field)
+			o := Parse(f)
+			for role, want := range tt.sends {
+				if got := o.Sends(role); got != want {
+					t.Errorf("Sends(%d) = %v, want %v", role, got, want)
+				}
+			}
+			for role, want := range tt.accepts {
+				if got := o.Accepts(role); got != want {
+					t.Errorf("Accepts(%d) = %v, want %v", role, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_Conflict(t *testing.T) {
+	type conflicts struct {
+		None     string
+		Resolver string `syncer:"conflict=server-wins"`
+		Combined string `syncer:"readonly, conflict=counter"`
+	}
+	typ := reflect.TypeOf(conflicts{})
+	tests := map[string]Options{
+		"None":     {},
+		"Resolver": {Conflict: "server-wins"},
+		"Combined": {ReadOnly: true, Conflict: "counter"},
+	}
+	for field, want := range tests {
+		f, _ := typ.FieldByName(field)
+		if got := Parse(f); got != want {
+			t.Errorf("Parse(%s) = %+v, want %+v", field, got, want)
+		}
+	}
+}